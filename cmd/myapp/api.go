@@ -3,13 +3,19 @@ package main
 import (
 	"cofee-shop-mongo/internal/auth"
 	"cofee-shop-mongo/internal/config"
+	"cofee-shop-mongo/internal/events"
 	"cofee-shop-mongo/internal/handlers"
 	"cofee-shop-mongo/internal/handlers/middleware"
+	"cofee-shop-mongo/internal/notify"
 	"cofee-shop-mongo/internal/repository"
 	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/models"
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
@@ -31,20 +37,82 @@ func NewAPIServer(config *config.Config, mux *http.ServeMux, db *mongo.Database,
 }
 
 func (as *APIServer) Run() {
+	// the indexes rely on the migrated data, the unique order ids in particular
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	if err := repository.Migrate(ctx, as.db); err != nil {
+		as.logger.Error("failed to migrate data", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	cancel()
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	if err := repository.EnsureIndexes(ctx, as.db); err != nil {
+		as.logger.Error("failed to create indexes", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	cancel()
+
+	txManager := repository.NewTxManager(as.db)
+
 	inventoryRepository := repository.NewInventoryRepository(as.db)
-	inventoryService := service.NewInventoryService(inventoryRepository)
+	menuRepository := repository.NewMenuRepository(as.db)
+	movementRepository := repository.NewMovementRepository(as.db)
+	lowStockNotifiers := []notify.LowStockNotifier{notify.NewLogNotifier(as.logger)}
+	if as.config.NotifyConfig.LowStockWebhookURL != "" {
+		lowStockNotifiers = append(lowStockNotifiers, notify.NewWebhookNotifier(as.config.NotifyConfig.LowStockWebhookURL, 5*time.Second))
+	}
+	lowStockNotifier := notify.NewNotifiers(as.logger, lowStockNotifiers...)
+	supplierRepository := repository.NewSupplierRepository(as.db)
+	inventoryService := service.NewInventoryService(inventoryRepository, movementRepository, menuRepository, supplierRepository, txManager, lowStockNotifier)
 	inventoryHandler := handlers.NewInventoryHandler(inventoryService, as.logger)
 	inventoryHandler.RegisterEndpoints(as.mux)
 
-	menuRepository := repository.NewMenuRepository(as.db)
-	menuService := service.NewMenuService(menuRepository)
+	supplierService := service.NewSupplierService(supplierRepository)
+	supplierHandler := handlers.NewSupplierHandler(supplierService, as.logger)
+	supplierHandler.RegisterEndpoints(as.mux)
+
+	purchaseOrderRepository := repository.NewPurchaseOrderRepository(as.db)
+	purchaseOrderService := service.NewPurchaseOrderService(purchaseOrderRepository, supplierService, inventoryService, txManager)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(purchaseOrderService, as.logger)
+	purchaseOrderHandler.RegisterEndpoints(as.mux)
+
+	categoryRepository := repository.NewCategoryRepository(as.db)
+	priceChangeRepository := repository.NewPriceChangeRepository(as.db)
+	location, err := time.LoadLocation(as.config.ShopConfig.Timezone)
+	if err != nil {
+		as.logger.Error("unknown shop timezone, using UTC", slog.String("timezone", as.config.ShopConfig.Timezone), slog.String("error", err.Error()))
+		location = time.UTC
+	}
+	menuService := service.NewMenuService(menuRepository, categoryRepository, priceChangeRepository, inventoryService, txManager, location)
 	menuHandler := handlers.NewMenuHandler(menuService, as.logger)
 	menuHandler.RegisterEndpoints(as.mux)
+	go as.applyDuePriceChanges(menuService, time.Duration(as.config.MenuConfig.PriceChangeSweepIntervalSeconds)*time.Second)
+	categoryHandler := handlers.NewCategoryHandler(menuService, as.logger)
+	categoryHandler.RegisterEndpoints(as.mux)
 
 	orderRepository := repository.NewOrderRepository(as.db)
-	orderService := service.NewOrderService(orderRepository, menuService, inventoryService) //order needs access to menu and inventory so you need to pass repo or service
-	orderHandler := handlers.NewOrderHandler(orderService, as.logger)
+	counterRepository := repository.NewCounterRepository(as.db)
+	ticketRepository := repository.NewTicketRepository(as.db)
+	var reservationTTL time.Duration
+	if as.config.OrderConfig.ReserveStock {
+		reservationTTL = time.Duration(as.config.OrderConfig.StockReservationTTLInSeconds) * time.Second
+	}
+	orderEvents := events.NewBus(as.config.OrderConfig.EventHistorySize)
+	var orderEventPublisher service.OrderEventPublisher = orderEvents
+	if as.config.OrderConfig.EventChangeStream {
+		// every instance follows the change stream, so the events of orders placed on other instances arrive too
+		orderEventPublisher = nil
+		go as.watchOrderEvents(orderRepository, orderEvents)
+	}
+	orderService := service.NewOrderService(orderRepository, counterRepository, ticketRepository, menuService, inventoryService, txManager, reservationTTL, orderEventPublisher) //order needs access to menu and inventory so you need to pass repo or service
+	idempotencyRepository := repository.NewIdempotencyRepository(as.db)
+	idempotent := middleware.Idempotency(idempotencyRepository, time.Duration(as.config.IdempotencyConfig.KeyTTLInSeconds)*time.Second, as.logger)
+	orderHandler := handlers.NewOrderHandler(orderService, orderEvents, idempotent, as.logger)
 	orderHandler.RegisterEndpoints(as.mux)
+	kitchenHandler := handlers.NewKitchenHandler(orderService, idempotent, as.logger)
+	kitchenHandler.RegisterEndpoints(as.mux)
+	if reservationTTL > 0 {
+		go as.releaseExpiredReservations(orderService, time.Duration(as.config.OrderConfig.ReservationSweepIntervalSeconds)*time.Second)
+	}
 
 	userRepository := repository.NewUserRepository(as.db)
 	userService := service.NewUserService(userRepository)
@@ -52,19 +120,79 @@ func (as *APIServer) Run() {
 	userHandler.RegisterEndpoints(as.mux)
 
 	reportRepository := repository.NewReportRepository(as.db)
-	reportService := service.NewReportService(reportRepository)
+	reportService := service.NewReportService(reportRepository, menuService)
 	reportHandler := handlers.NewReportHandler(reportService)
 	reportHandler.RegisterEndpoints(as.mux)
 
+	integrityRepository := repository.NewIntegrityRepository(as.db)
+	integrityService := service.NewIntegrityService(integrityRepository, time.Duration(as.config.IntegrityConfig.CheckTimeoutInSeconds)*time.Second)
+	adminHandler := handlers.NewAdminHandler(integrityService, as.logger)
+	adminHandler.RegisterEndpoints(as.mux)
+
 	auth.SetSecret(as.config.JWTConfig.JWTSecret)
 	authService := service.NewAuthService(userRepository, as.config.JWTConfig)
 	authHandler := handlers.NewAuthHandler(authService, as.logger)
 	authHandler.RegisterEndpoints(as.mux)
 
-	mWChain := middleware.NewMiddleWareChain(middleware.Recovery, middleware.ContextMW)
+	// the event streams bypass ContextMW, every other request gets its timeout
+	root := http.NewServeMux()
+	root.Handle("/", middleware.ContextMW(as.mux))
+	orderHandler.RegisterStreamEndpoints(root)
 
 	address := fmt.Sprintf("0.0.0.0:%s", as.config.Port)
 	as.logger.Info("starting server", slog.String("address", address))
-	http.ListenAndServe(address, mWChain(as.mux))
+	http.ListenAndServe(address, middleware.Recovery(root))
+
+}
+
+// releaseExpiredReservations periodically frees the stock held by orders whose reservation ran out.
+func (as *APIServer) releaseExpiredReservations(orderService *service.OrderService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		released, err := orderService.ReleaseExpiredReservations(ctx)
+		cancel()
+		if err != nil {
+			as.logger.Error("failed to release expired reservations", slog.String("error", err.Error()))
+			continue
+		}
+		if released > 0 {
+			as.logger.Info("released expired reservations", slog.Int("orders", released))
+		}
+	}
+}
+
+// watchOrderEvents feeds the order events of the change stream to the bus, and resumes the stream where it
+// stopped when it fails.
+func (as *APIServer) watchOrderEvents(orderRepository *repository.OrderRepository, bus *events.Bus) {
+	var resumeAfter string
+	for {
+		var err error
+		resumeAfter, err = orderRepository.WatchOrderEvents(context.Background(), resumeAfter, func(event models.OrderEvent) {
+			bus.Publish(context.Background(), event)
+		})
+		as.logger.Error("order change stream stopped, restarting", slog.String("error", err.Error()))
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// applyDuePriceChanges periodically puts scheduled menu price changes into effect.
+func (as *APIServer) applyDuePriceChanges(menuService *service.MenuService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		applied, err := menuService.ApplyDuePriceChanges(ctx)
+		cancel()
+		if err != nil {
+			as.logger.Error("failed to apply scheduled price changes", slog.String("error", err.Error()))
+			continue
+		}
+		if applied > 0 {
+			as.logger.Info("applied scheduled price changes", slog.Int("changes", applied))
+		}
+	}
 }
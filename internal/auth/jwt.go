@@ -16,12 +16,32 @@ type contextKey string
 const (
 	UserIDKey contextKey = "user_id"
 	RoleKey   contextKey = "role"
+	// OrderTokenKey holds the access token a guest presented for an order.
+	OrderTokenKey contextKey = "order_token"
 )
 
 var secret string
 
-func SetSecret(secret string) {
-	secret = secret
+func SetSecret(s string) {
+	secret = s
+}
+
+// UserIDFromContext returns the id of the authenticated user, or "" for anonymous requests.
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(UserIDKey).(string)
+	return userID
+}
+
+// RoleFromContext returns the role of the authenticated user, or "" for anonymous requests.
+func RoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(RoleKey).(string)
+	return role
+}
+
+// OrderTokenFromContext returns the order access token of the request, or "" if it has none.
+func OrderTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(OrderTokenKey).(string)
+	return token
 }
 
 func WithJWTAuth(requiredRole []string, next http.HandlerFunc) http.HandlerFunc {
@@ -32,22 +52,9 @@ func WithJWTAuth(requiredRole []string, next http.HandlerFunc) http.HandlerFunc
 			utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("missing token"))
 			return
 		}
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := validateJWT(tokenString)
+		userID, role, err := authenticate(authHeader)
 		if err != nil {
-			utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid token"))
-			return
-		}
-		//extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid token claims"))
-			return
-		}
-		userID, ok := claims["sub"].(string)
-		role, roleOk := claims["role"].(string)
-		if !ok || !roleOk {
-			utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid token data"))
+			utils.WriteError(w, http.StatusUnauthorized, err)
 			return
 		}
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
@@ -58,7 +65,7 @@ func WithJWTAuth(requiredRole []string, next http.HandlerFunc) http.HandlerFunc
 				accessFlag = true
 			}
 		}
-		if !ok || !accessFlag {
+		if !accessFlag {
 			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("access denied: required role %s", requiredRole))
 			return
 		}
@@ -66,6 +73,46 @@ func WithJWTAuth(requiredRole []string, next http.HandlerFunc) http.HandlerFunc
 	}
 }
 
+// WithOptionalJWTAuth authenticates requests that carry a token like WithJWTAuth does, without requiring a
+// role, and lets requests without one through anonymously.
+func WithOptionalJWTAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userID, role, err := authenticate(authHeader)
+		if err != nil {
+			utils.WriteError(w, http.StatusUnauthorized, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		ctx = context.WithValue(ctx, RoleKey, role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// authenticate validates the bearer token of the Authorization header and returns the user and role it was issued for.
+func authenticate(authHeader string) (userID, role string, err error) {
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	token, err := validateJWT(tokenString)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid token")
+	}
+	//extract claims
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", "", fmt.Errorf("invalid token claims")
+	}
+	userID, ok = claims["sub"].(string)
+	role, roleOk := claims["role"].(string)
+	if !ok || !roleOk {
+		return "", "", fmt.Errorf("invalid token data")
+	}
+	return userID, role, nil
+}
+
 func CreateJWT(userID, role string, expiration int64) (string, error) {
 	expirationInSeconds := time.Second * time.Duration(expiration)
 
@@ -13,12 +13,52 @@ type JWTConfig struct {
 	JWTExpirationInSeconds int64
 }
 
+type OrderConfig struct {
+	ReserveStock                    bool
+	StockReservationTTLInSeconds    int64
+	ReservationSweepIntervalSeconds int64
+	// EventHistorySize is how many order events are kept for SSE clients that reconnect with Last-Event-ID.
+	EventHistorySize int
+	// EventChangeStream takes the order events from a MongoDB change stream, which needs a replica set, so that
+	// the clients of every server instance see the orders placed on the others.
+	EventChangeStream bool
+}
+
+type NotifyConfig struct {
+	LowStockWebhookURL string
+}
+
+type MenuConfig struct {
+	PriceChangeSweepIntervalSeconds int64
+}
+
+type IdempotencyConfig struct {
+	// KeyTTLInSeconds is how long the response to a request with an Idempotency-Key is replayed to retries.
+	KeyTTLInSeconds int64
+}
+
+type IntegrityConfig struct {
+	// CheckTimeoutInSeconds bounds an integrity check, which scans every collection in the background.
+	CheckTimeoutInSeconds int64
+}
+
+type ShopConfig struct {
+	// Timezone is the IANA name of the shop's timezone, menu schedules are in local shop time.
+	Timezone string
+}
+
 type Config struct {
-	Host          string
-	Port          string
-	MongoUser     string
-	MongoPassword string
-	JWTConfig     JWTConfig
+	Host              string
+	Port              string
+	MongoUser         string
+	MongoPassword     string
+	JWTConfig         JWTConfig
+	OrderConfig       OrderConfig
+	NotifyConfig      NotifyConfig
+	ShopConfig        ShopConfig
+	MenuConfig        MenuConfig
+	IdempotencyConfig IdempotencyConfig
+	IntegrityConfig   IntegrityConfig
 }
 
 func LoadConfig() *Config {
@@ -30,11 +70,34 @@ func LoadConfig() *Config {
 		JWTSecret:              getEnv("JWT_SECRET", "secretnword123"),
 		JWTExpirationInSeconds: getEnvAsInt("JWT_EXPIRATION_IN_SECONDS", 3600*24*7),
 	}
+	ordercfg := OrderConfig{
+		ReserveStock:                    getEnvAsBool("RESERVE_STOCK", false),
+		StockReservationTTLInSeconds:    getEnvAsInt("STOCK_RESERVATION_TTL_IN_SECONDS", 60*30),
+		ReservationSweepIntervalSeconds: getEnvAsInt("RESERVATION_SWEEP_INTERVAL_IN_SECONDS", 60),
+		EventHistorySize:                int(getEnvAsInt("ORDER_EVENT_HISTORY_SIZE", 500)),
+		EventChangeStream:               getEnvAsBool("ORDER_EVENTS_CHANGE_STREAM", false),
+	}
 	cfg := Config{
 		MongoUser:     getEnv("MONGO_USER", "cofeeStaff"),
 		MongoPassword: getEnv("MONGO_PASSWORD", "pass123"),
 		Port:          getEnv("PORT", "8080"),
 		JWTConfig:     jwtcfg,
+		OrderConfig:   ordercfg,
+		NotifyConfig: NotifyConfig{
+			LowStockWebhookURL: getEnv("LOW_STOCK_WEBHOOK_URL", ""),
+		},
+		ShopConfig: ShopConfig{
+			Timezone: getEnv("SHOP_TIMEZONE", "UTC"),
+		},
+		MenuConfig: MenuConfig{
+			PriceChangeSweepIntervalSeconds: getEnvAsInt("PRICE_CHANGE_SWEEP_INTERVAL_IN_SECONDS", 60),
+		},
+		IdempotencyConfig: IdempotencyConfig{
+			KeyTTLInSeconds: getEnvAsInt("IDEMPOTENCY_KEY_TTL_IN_SECONDS", 3600*24),
+		},
+		IntegrityConfig: IntegrityConfig{
+			CheckTimeoutInSeconds: getEnvAsInt("INTEGRITY_CHECK_TIMEOUT_IN_SECONDS", 60*10),
+		},
 	}
 	return &cfg
 }
@@ -64,3 +127,16 @@ func getEnvAsInt(key string, fallback int64) int64 {
 
 	return fallback
 }
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+
+		return b
+	}
+
+	return fallback
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// subscriptionBuffer is how many events a subscriber may fall behind before it is dropped.
+const subscriptionBuffer = 64
+
+// Bus hands order events to the SSE connections of this server. It keeps the last events it published, so
+// that a client reconnecting with Last-Event-ID gets the ones it missed.
+type Bus struct {
+	mu          sync.Mutex
+	boot        string
+	seq         uint64
+	history     []models.OrderEvent
+	historySize int
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBus(historySize int) *Bus {
+	return &Bus{
+		// ids restart with the server, the boot prefix keeps an old id from matching a new event
+		boot:        utils.GenerateRandomString(6),
+		historySize: historySize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription is one connection listening to the bus.
+type Subscription struct {
+	// Events delivers the events published after Subscribe. It is closed when the subscriber falls too far
+	// behind, the client should reconnect with the id of the last event it got.
+	Events <-chan models.OrderEvent
+	// Missed are the events published after the one given to Subscribe.
+	Missed []models.OrderEvent
+	// Reset is set when the event given to Subscribe is no longer known, the client has to reload the orders
+	// as some events may be lost.
+	Reset bool
+
+	events chan models.OrderEvent
+	bus    *Bus
+}
+
+// Publish sends the event to every subscriber. Events without an id are numbered by the bus, events from a
+// change stream keep the id they were given, which is the same on every server instance.
+func (b *Bus) Publish(_ context.Context, event models.OrderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if event.ID == "" {
+		b.seq++
+		event.ID = fmt.Sprintf("%s-%d", b.boot, b.seq)
+	}
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = slices.Delete(b.history, 0, len(b.history)-b.historySize)
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe starts listening to the bus. lastEventID is the Last-Event-ID of a reconnecting client, or "".
+func (b *Bus) Subscribe(lastEventID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan models.OrderEvent, subscriptionBuffer)
+	sub := &Subscription{Events: events, events: events, bus: b}
+	if lastEventID != "" {
+		i := slices.IndexFunc(b.history, func(event models.OrderEvent) bool { return event.ID == lastEventID })
+		if i < 0 {
+			sub.Reset = true
+		} else {
+			sub.Missed = slices.Clone(b.history[i+1:])
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+func (b *Bus) drop(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+type IntegrityService interface {
+	StartIntegrityCheck(ctx context.Context) (models.IntegrityRun, error)
+	GetIntegrityReport(ctx context.Context, query models.ListQuery) (models.IntegrityReport, error)
+}
+
+type AdminHandler struct {
+	Integrity IntegrityService
+	Logger    *slog.Logger
+}
+
+func NewAdminHandler(integrity IntegrityService, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{integrity, logger}
+}
+
+func (h *AdminHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("POST /admin/integrity", auth.WithJWTAuth(models.AdminAccess, h.startIntegrityCheck))
+	mux.HandleFunc("POST /admin/integrity/", auth.WithJWTAuth(models.AdminAccess, h.startIntegrityCheck))
+	mux.HandleFunc("GET /admin/integrity", auth.WithJWTAuth(models.AdminAccess, h.getIntegrityReport))
+	mux.HandleFunc("GET /admin/integrity/", auth.WithJWTAuth(models.AdminAccess, h.getIntegrityReport))
+}
+
+func (h *AdminHandler) startIntegrityCheck(w http.ResponseWriter, r *http.Request) {
+	run, err := h.Integrity.StartIntegrityCheck(r.Context())
+	if err != nil {
+		if errors.Is(err, service.ErrCheckInProgress) {
+			utils.WriteErrorCode(w, http.StatusConflict, "integrity_check_running", errors.New("an integrity check is running already"))
+			return
+		}
+		h.Logger.Error("Failed to start integrity check", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not start integrity check, please try again later"))
+		return
+	}
+
+	h.Logger.Info("Started integrity check", "run_id", run.RunID)
+	utils.WriteJSON(w, http.StatusAccepted, run)
+}
+
+func (h *AdminHandler) getIntegrityReport(w http.ResponseWriter, r *http.Request) {
+	query, err := utils.ParseListQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.Integrity.GetIntegrityReport(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, errors.New("no integrity check was run yet"))
+			return
+		}
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_query", err)
+			return
+		}
+		h.Logger.Error("Failed to fetch integrity report", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve integrity report, please try again later"))
+		return
+	}
+
+	h.Logger.Info("Fetched integrity report", "run_id", report.RunID, "status", report.Status)
+	utils.WriteJSON(w, http.StatusOK, report)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"cofee-shop-mongo/internal/auth"
 	"cofee-shop-mongo/internal/utils"
 	"cofee-shop-mongo/models"
 	"context"
@@ -11,6 +12,8 @@ import (
 type ReportService interface {
 	GetPopularItems(ctx context.Context) ([]models.PopularItem, error)
 	GetTotalSales(ctx context.Context) (float64, error)
+	GetSalesByItem(ctx context.Context) ([]models.ItemSales, error)
+	GetMargins(ctx context.Context) ([]models.MenuItemCost, error)
 }
 
 type ReportHandler struct {
@@ -27,6 +30,12 @@ func (h *ReportHandler) RegisterEndpoints(mux *http.ServeMux) {
 
 	mux.HandleFunc("GET /reports/popular-items", h.GetPopularItems)
 	mux.HandleFunc("GET /reports/popular-items/", h.GetPopularItems)
+
+	mux.HandleFunc("GET /reports/sales-by-item", h.GetSalesByItem)
+	mux.HandleFunc("GET /reports/sales-by-item/", h.GetSalesByItem)
+
+	mux.HandleFunc("GET /reports/margins", auth.WithJWTAuth(models.StaffAccess, h.GetMargins))
+	mux.HandleFunc("GET /reports/margins/", auth.WithJWTAuth(models.StaffAccess, h.GetMargins))
 }
 
 func (h *ReportHandler) GetTotalSales(w http.ResponseWriter, r *http.Request) {
@@ -46,3 +55,21 @@ func (h *ReportHandler) GetPopularItems(w http.ResponseWriter, r *http.Request)
 	}
 	utils.WriteJSON(w, http.StatusOK, popularItems)
 }
+
+func (h *ReportHandler) GetSalesByItem(w http.ResponseWriter, r *http.Request) {
+	sales, err := h.Service.GetSalesByItem(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("couldn't get sales by item: %w", err))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, sales)
+}
+
+func (h *ReportHandler) GetMargins(w http.ResponseWriter, r *http.Request) {
+	margins, err := h.Service.GetMargins(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("couldn't get margins: %w", err))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, margins)
+}
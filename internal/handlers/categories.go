@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+type CategoryService interface {
+	CreateCategory(ctx context.Context, category models.Category) (string, error)
+	GetAllCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoryById(ctx context.Context, id string) (models.Category, error)
+	UpdateCategoryById(ctx context.Context, id string, category models.Category) error
+	DeleteCategoryById(ctx context.Context, id string) error
+}
+
+type CategoryHandler struct {
+	Service CategoryService
+	Logger  *slog.Logger
+}
+
+func NewCategoryHandler(service CategoryService, logger *slog.Logger) *CategoryHandler {
+	return &CategoryHandler{service, logger}
+}
+
+func (h *CategoryHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("POST /categories", auth.WithJWTAuth(models.StaffAccess, h.createCategory))
+	mux.HandleFunc("POST /categories/", auth.WithJWTAuth(models.StaffAccess, h.createCategory))
+
+	mux.HandleFunc("GET /categories", h.getAllCategories)
+	mux.HandleFunc("GET /categories/", h.getAllCategories)
+
+	mux.HandleFunc("GET /categories/{id}", h.getCategoryById)
+	mux.HandleFunc("GET /categories/{id}/", h.getCategoryById)
+
+	mux.HandleFunc("PUT /categories/{id}", auth.WithJWTAuth(models.StaffAccess, h.updateCategoryById))
+	mux.HandleFunc("PUT /categories/{id}/", auth.WithJWTAuth(models.StaffAccess, h.updateCategoryById))
+
+	mux.HandleFunc("DELETE /categories/{id}", auth.WithJWTAuth(models.StaffAccess, h.deleteCategoryById))
+	mux.HandleFunc("DELETE /categories/{id}/", auth.WithJWTAuth(models.StaffAccess, h.deleteCategoryById))
+}
+
+func (h *CategoryHandler) createCategory(w http.ResponseWriter, r *http.Request) {
+	var category models.Category
+
+	if err := utils.ParseJSON(r, &category); err != nil {
+		h.Logger.Error("Failed to parse category request", "error", err)
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		return
+	}
+	if err := validateCategory(category); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id, err := h.Service.CreateCategory(r.Context(), category)
+	if err != nil {
+		h.Logger.Error("Failed to create category", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not create category, please try again later"))
+		return
+	}
+
+	h.Logger.Info("New category created", "id", id)
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"message": "New category created successfully", "id": id})
+}
+
+func (h *CategoryHandler) getAllCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.Service.GetAllCategories(r.Context())
+	if err != nil {
+		h.Logger.Error("Failed to fetch categories", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve categories, please try again later"))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, categories)
+}
+
+func (h *CategoryHandler) getCategoryById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	category, err := h.Service.GetCategoryById(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to fetch category", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve category, please try again later"))
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, category)
+}
+
+func (h *CategoryHandler) updateCategoryById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var category models.Category
+
+	if err := utils.ParseJSON(r, &category); err != nil {
+		h.Logger.Error("Failed to parse category update request", "id", id, "error", err)
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		return
+	}
+	if category.CategoryID == "" {
+		category.CategoryID = id
+	}
+	if category.CategoryID != id {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("you cant change categoryID"))
+		return
+	}
+	if err := validateCategory(category); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.Service.UpdateCategoryById(r.Context(), id, category)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to update category", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not update category, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Updated category", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Category updated successfully"})
+}
+
+func (h *CategoryHandler) deleteCategoryById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	err := h.Service.DeleteCategoryById(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category \"%s\" not found", id))
+		case errors.Is(err, service.ErrInUse):
+			utils.WriteErrorCode(w, http.StatusConflict, "in_use", err)
+		default:
+			h.Logger.Error("Failed to delete category", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not delete category, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Deleted category", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Category deleted successfully"})
+}
+
+func validateCategory(category models.Category) error {
+	if category.CategoryID == "" {
+		return errors.New("category ID cannot be empty")
+	}
+	if category.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	return nil
+}
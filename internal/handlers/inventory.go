@@ -3,6 +3,8 @@ package handlers
 import (
 	"cofee-shop-mongo/internal/auth"
 	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/internal/units"
 	"cofee-shop-mongo/internal/utils"
 	"cofee-shop-mongo/models"
 	"context"
@@ -10,14 +12,18 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 )
 
 type InventoryService interface {
 	CreateInventoryItem(ctx context.Context, item models.InventoryItem) (string, error)
-	GetAllInventoryItems(ctx context.Context) ([]models.InventoryItem, error)
+	GetAllInventoryItems(ctx context.Context, filter models.InventoryFilter, query models.ListQuery) (models.Page[models.InventoryItem], error)
 	GetInventoryItemById(ctx context.Context, InventoryId string) (models.InventoryItem, error)
 	DeleteInventoryItemById(ctx context.Context, InventoryId string) error
-	UpdateInventoryItemById(ctx context.Context, InventoryId string, item models.InventoryItem) error
+	RestoreInventoryItemById(ctx context.Context, InventoryId string) error
+	UpdateInventoryItemById(ctx context.Context, InventoryId string, item models.InventoryItem, movement models.InventoryMovement) error
+	GetInventoryMovements(ctx context.Context, InventoryId, from, to string) ([]models.InventoryMovement, error)
+	GetLowStockItems(ctx context.Context) ([]models.InventoryItem, error)
 }
 
 type InventoryHandler struct {
@@ -36,6 +42,9 @@ func (h *InventoryHandler) RegisterEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc("GET /inventory", auth.WithJWTAuth(models.StaffAccess, h.getAllInventoryItems))
 	mux.HandleFunc("GET /inventory/", auth.WithJWTAuth(models.StaffAccess, h.getAllInventoryItems))
 
+	mux.HandleFunc("GET /inventory/low-stock", auth.WithJWTAuth(models.StaffAccess, h.getLowStockItems))
+	mux.HandleFunc("GET /inventory/low-stock/{$}", auth.WithJWTAuth(models.StaffAccess, h.getLowStockItems))
+
 	mux.HandleFunc("GET /inventory/{id}", auth.WithJWTAuth(models.StaffAccess, h.getInventoryItemById))
 	mux.HandleFunc("GET /inventory/{id}/", auth.WithJWTAuth(models.StaffAccess, h.getInventoryItemById))
 
@@ -44,6 +53,12 @@ func (h *InventoryHandler) RegisterEndpoints(mux *http.ServeMux) {
 
 	mux.HandleFunc("DELETE /inventory/{id}", auth.WithJWTAuth(models.StaffAccess, h.deleteInventoryItemById))
 	mux.HandleFunc("DELETE /inventory/{id}/", auth.WithJWTAuth(models.StaffAccess, h.deleteInventoryItemById))
+
+	mux.HandleFunc("POST /inventory/{id}/restore", auth.WithJWTAuth(models.StaffAccess, h.restoreInventoryItemById))
+	mux.HandleFunc("POST /inventory/{id}/restore/", auth.WithJWTAuth(models.StaffAccess, h.restoreInventoryItemById))
+
+	mux.HandleFunc("GET /inventory/{id}/movements", auth.WithJWTAuth(models.StaffAccess, h.getInventoryMovements))
+	mux.HandleFunc("GET /inventory/{id}/movements/", auth.WithJWTAuth(models.StaffAccess, h.getInventoryMovements))
 }
 
 func (h *InventoryHandler) createInventoryItem(w http.ResponseWriter, r *http.Request) {
@@ -61,6 +76,11 @@ func (h *InventoryHandler) createInventoryItem(w http.ResponseWriter, r *http.Re
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	// an item can run out later, but it is created with some stock
+	if item.Quantity == 0 {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("quantity must be greater than zero"))
+		return
+	}
 
 	id, err := h.Service.CreateInventoryItem(r.Context(), item)
 	if err != nil {
@@ -74,14 +94,41 @@ func (h *InventoryHandler) createInventoryItem(w http.ResponseWriter, r *http.Re
 }
 
 func (h *InventoryHandler) getAllInventoryItems(w http.ResponseWriter, r *http.Request) {
-	items, err := h.Service.GetAllInventoryItems(r.Context())
+	query, err := utils.ParseListQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := models.InventoryFilter{NamePrefix: r.URL.Query().Get("name")}
+	if filter.IncludeArchived, err = utils.ParseIncludeArchived(r); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := h.Service.GetAllInventoryItems(r.Context(), filter, query)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_query", err)
+			return
+		}
 		h.Logger.Error("Failed to fetch inventory items", "error", err)
 		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve inventory items, please try again later"))
 		return
 	}
 
-	h.Logger.Info("Fetched all inventory items", "count", len(items))
+	h.Logger.Info("Fetched inventory items", "count", len(page.Items))
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+func (h *InventoryHandler) getLowStockItems(w http.ResponseWriter, r *http.Request) {
+	items, err := h.Service.GetLowStockItems(r.Context())
+	if err != nil {
+		h.Logger.Error("Failed to fetch low stock items", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve low stock items, please try again later"))
+		return
+	}
+
+	h.Logger.Info("Fetched low stock items", "count", len(items))
 	utils.WriteJSON(w, http.StatusOK, items)
 }
 
@@ -106,14 +153,22 @@ func (h *InventoryHandler) getInventoryItemById(w http.ResponseWriter, r *http.R
 
 func (h *InventoryHandler) updateInventoryItemById(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	var updatedItem models.InventoryItem
+	var payload models.InventoryUpdatePayload
 
-	err := utils.ParseJSON(r, &updatedItem)
+	err := utils.ParseJSON(r, &payload)
 	if err != nil {
 		h.Logger.Error("Failed to parse inventory item update request", "id", id, "error", err)
 		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
 		return
 	}
+	updatedItem := payload.InventoryItem
+	if payload.Reason == "" {
+		payload.Reason = models.MovementManualAdjustment
+	}
+	if !slices.Contains(models.ManualMovementReasons, payload.Reason) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("reason must be one of %v", models.ManualMovementReasons))
+		return
+	}
 	//validation user input
 	if updatedItem.IngredientID == "" {
 		updatedItem.IngredientID = id
@@ -128,16 +183,19 @@ func (h *InventoryHandler) updateInventoryItemById(w http.ResponseWriter, r *htt
 		return
 	}
 
-	err = h.Service.UpdateInventoryItemById(r.Context(), id, updatedItem)
+	movement := models.InventoryMovement{Reason: payload.Reason, Note: payload.Note}
+	err = h.Service.UpdateInventoryItemById(r.Context(), id, updatedItem, movement)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			h.Logger.Error("Inventory item not found", "id", id, "error", err)
 			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("inventory item \"%s\" not found", id))
+		} else if errors.Is(err, service.ErrInvalidInventoryItem) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_inventory_item", err)
 		} else {
 			h.Logger.Error("Failed to update inventory item", "id", id, "error", err)
 			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not update inventory item, please try again later"))
-			return
 		}
+		return
 	}
 
 	h.Logger.Info("Updated inventory item", "id", id)
@@ -149,7 +207,11 @@ func (h *InventoryHandler) deleteInventoryItemById(w http.ResponseWriter, r *htt
 
 	err := h.Service.DeleteInventoryItemById(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
+		var inUse *service.InUseError
+		if errors.As(err, &inUse) {
+			utils.WriteJSON(w, http.StatusConflict, map[string]any{"error": inUse.Error(), "code": "in_use", "menu_items": inUse.MenuItems})
+			return
+		} else if errors.Is(err, repository.ErrNotFound) {
 			h.Logger.Error("Inventory item not found", "id", id, "error", err)
 			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("inventory item \"%s\" not found", id))
 			return
@@ -164,6 +226,48 @@ func (h *InventoryHandler) deleteInventoryItemById(w http.ResponseWriter, r *htt
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Inventory item deleted successfully"})
 }
 
+func (h *InventoryHandler) restoreInventoryItemById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	err := h.Service.RestoreInventoryItemById(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("inventory item \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to restore inventory item", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not restore inventory item, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Restored inventory item", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Inventory item restored successfully"})
+}
+
+func (h *InventoryHandler) getInventoryMovements(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	from, to, err := utils.ParseDateRange(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	movements, err := h.Service.GetInventoryMovements(r.Context(), id, from, to)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			h.Logger.Error("Inventory item not found", "id", id, "error", err)
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("inventory item \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to fetch inventory movements", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve inventory movements, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Fetched inventory movements", "id", id, "count", len(movements))
+	utils.WriteJSON(w, http.StatusOK, movements)
+}
+
 func validateInventoryItem(item models.InventoryItem) error {
 	if item.IngredientID == "" {
 		return errors.New("ingredient ID cannot be empty")
@@ -171,11 +275,26 @@ func validateInventoryItem(item models.InventoryItem) error {
 	if item.Name == "" {
 		return errors.New("name cannot be empty")
 	}
-	if item.Quantity <= 0 {
-		return errors.New("quantity must be greater than zero")
+	if item.Quantity < 0 {
+		return errors.New("quantity cannot be negative")
 	}
 	if item.Unit == "" {
 		return errors.New("unit cannot be empty")
 	}
+	if _, err := units.Parse(item.Unit); err != nil {
+		return err
+	}
+	if item.ReorderPoint < 0 {
+		return errors.New("reorder point cannot be negative")
+	}
+	if item.ReorderQuantity < 0 {
+		return errors.New("reorder quantity cannot be negative")
+	}
+	if item.UnitCost < 0 {
+		return errors.New("unit cost cannot be negative")
+	}
+	if item.Nutrition.Kcal < 0 || item.Nutrition.Sugar < 0 || item.Nutrition.Caffeine < 0 {
+		return errors.New("nutrition facts cannot be negative")
+	}
 	return nil
 }
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/handlers/middleware"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type KitchenService interface {
+	GetTickets(ctx context.Context, filter models.TicketFilter) ([]models.KitchenTicket, error)
+	BumpTicket(ctx context.Context, ticketID string) (models.KitchenTicket, error)
+}
+
+type KitchenHandler struct {
+	Service KitchenService
+	// Idempotent wraps the bump endpoint, so that a retried bump doesn't skip a status.
+	Idempotent middleware.Middleware
+	Logger     *slog.Logger
+}
+
+func NewKitchenHandler(kitchenService KitchenService, idempotent middleware.Middleware, logger *slog.Logger) *KitchenHandler {
+	return &KitchenHandler{kitchenService, idempotent, logger}
+}
+
+func (h *KitchenHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("GET /kitchen/tickets", auth.WithJWTAuth(models.StaffAccess, h.GetTickets))
+	mux.HandleFunc("GET /kitchen/tickets/", auth.WithJWTAuth(models.StaffAccess, h.GetTickets))
+
+	mux.HandleFunc("POST /kitchen/tickets/{id}/bump", auth.WithJWTAuth(models.StaffAccess, h.idempotent(h.BumpTicket)))
+	mux.HandleFunc("POST /kitchen/tickets/{id}/bump/", auth.WithJWTAuth(models.StaffAccess, h.idempotent(h.BumpTicket)))
+}
+
+func (h *KitchenHandler) idempotent(fn http.HandlerFunc) http.HandlerFunc {
+	return h.Idempotent(fn).ServeHTTP
+}
+
+func (h *KitchenHandler) GetTickets(w http.ResponseWriter, r *http.Request) {
+	filter := models.TicketFilter{Station: r.URL.Query().Get("station")}
+	if value := r.URL.Query().Get("status"); value != "" {
+		filter.Statuses = strings.Split(value, ",")
+	}
+
+	tickets, err := h.Service.GetTickets(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownTicketStatus) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "unknown_status", err)
+			return
+		}
+		h.Logger.Error("Failed to get kitchen tickets", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, tickets)
+}
+
+func (h *KitchenHandler) BumpTicket(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ticket, err := h.Service.BumpTicket(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			utils.WriteErrorCode(w, http.StatusNotFound, "not_found", err)
+		case errors.Is(err, service.ErrIllegalTransition):
+			utils.WriteErrorCode(w, http.StatusConflict, "illegal_transition", err)
+		default:
+			h.Logger.Error("Failed to bump kitchen ticket", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	h.Logger.Info("Kitchen ticket bumped", "id", id, "status", ticket.Status)
+	utils.WriteJSON(w, http.StatusOK, ticket)
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"cofee-shop-mongo/internal/auth"
 	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/internal/units"
 	"cofee-shop-mongo/internal/utils"
 	"cofee-shop-mongo/models"
 	"context"
@@ -10,14 +12,23 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type MenuService interface {
 	CreateMenuItem(ctx context.Context, item models.MenuItem) (string, error)
-	GetAllMenuItems(ctx context.Context) ([]models.MenuItem, error)
+	GetAllMenuItems(ctx context.Context, filter models.MenuFilter, query models.ListQuery) (models.Page[models.MenuItem], error)
 	GetMenuItemById(ctx context.Context, id string) (models.MenuItem, error)
 	UpdateMenuItemById(ctx context.Context, id string, item models.MenuItem) error
 	DeleteMenuItemById(ctx context.Context, id string) error
+	RestoreMenuItemById(ctx context.Context, id string) error
+	GetMenuItemCost(ctx context.Context, id string) ([]models.MenuItemCost, error)
+	GetPriceHistory(ctx context.Context, id string) ([]models.PriceChange, error)
+	SchedulePriceChange(ctx context.Context, id string, payload models.PriceChangePayload) (models.PriceChange, error)
+	CancelPriceChange(ctx context.Context, id, changeID string) error
 }
 
 type MenuHandler struct {
@@ -44,6 +55,21 @@ func (h *MenuHandler) RegisterEndpoints(mux *http.ServeMux) {
 
 	mux.HandleFunc("DELETE /menu/{id}", auth.WithJWTAuth(models.StaffAccess, h.deleteMenuItemById))
 	mux.HandleFunc("DELETE /menu/{id}/", auth.WithJWTAuth(models.StaffAccess, h.deleteMenuItemById))
+
+	mux.HandleFunc("POST /menu/{id}/restore", auth.WithJWTAuth(models.StaffAccess, h.restoreMenuItemById))
+	mux.HandleFunc("POST /menu/{id}/restore/", auth.WithJWTAuth(models.StaffAccess, h.restoreMenuItemById))
+
+	mux.HandleFunc("GET /menu/{id}/cost", auth.WithJWTAuth(models.StaffAccess, h.getMenuItemCost))
+	mux.HandleFunc("GET /menu/{id}/cost/", auth.WithJWTAuth(models.StaffAccess, h.getMenuItemCost))
+
+	mux.HandleFunc("GET /menu/{id}/price-history", auth.WithJWTAuth(models.StaffAccess, h.getPriceHistory))
+	mux.HandleFunc("GET /menu/{id}/price-history/", auth.WithJWTAuth(models.StaffAccess, h.getPriceHistory))
+
+	mux.HandleFunc("POST /menu/{id}/price-changes", auth.WithJWTAuth(models.StaffAccess, h.schedulePriceChange))
+	mux.HandleFunc("POST /menu/{id}/price-changes/", auth.WithJWTAuth(models.StaffAccess, h.schedulePriceChange))
+
+	mux.HandleFunc("DELETE /menu/{id}/price-changes/{changeId}", auth.WithJWTAuth(models.StaffAccess, h.cancelPriceChange))
+	mux.HandleFunc("DELETE /menu/{id}/price-changes/{changeId}/", auth.WithJWTAuth(models.StaffAccess, h.cancelPriceChange))
 }
 
 func (h *MenuHandler) createMenuItem(w http.ResponseWriter, r *http.Request) {
@@ -63,6 +89,10 @@ func (h *MenuHandler) createMenuItem(w http.ResponseWriter, r *http.Request) {
 
 	id, err := h.Service.CreateMenuItem(r.Context(), item)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidMenuItem) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_menu_item", err)
+			return
+		}
 		h.Logger.Error("Failed to create menu item", "error", err)
 		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not create menu item, please try again later"))
 		return
@@ -73,15 +103,60 @@ func (h *MenuHandler) createMenuItem(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *MenuHandler) getAllMenuItems(w http.ResponseWriter, r *http.Request) {
-	items, err := h.Service.GetAllMenuItems(r.Context())
+	query, err := utils.ParseListQuery(r)
 	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := models.MenuFilter{CategoryID: r.URL.Query().Get("category"), At: time.Now()}
+	for param, bound := range map[string]*float64{"min_price": &filter.MinPrice, "max_price": &filter.MaxPrice} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		if *bound, err = strconv.ParseFloat(value, 64); err != nil || *bound < 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s must be a non-negative number", param))
+			return
+		}
+	}
+	if value := r.URL.Query().Get("at"); value != "" {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, errors.New("at must be an RFC3339 timestamp"))
+			return
+		}
+		filter.At = at
+	}
+	if r.URL.Query().Get("all") == "true" {
+		filter.At = time.Time{}
+	}
+	if value := r.URL.Query().Get("exclude_allergens"); value != "" {
+		filter.ExcludeAllergens = strings.Split(value, ",")
+	}
+	if filter.IncludeArchived, err = utils.ParseIncludeArchived(r); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if value := r.URL.Query().Get("available"); value != "" {
+		if filter.OnlyAvailable, err = strconv.ParseBool(value); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, errors.New("available must be true or false"))
+			return
+		}
+	}
+
+	page, err := h.Service.GetAllMenuItems(r.Context(), filter, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_query", err)
+			return
+		}
 		h.Logger.Error("Failed to fetch menu items", "error", err)
 		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve menu items, please try again later"))
 		return
 	}
 
-	h.Logger.Info("Fetched all menu items", "count", len(items))
-	utils.WriteJSON(w, http.StatusOK, items)
+	h.Logger.Info("Fetched menu items", "count", len(page.Items))
+	utils.WriteJSON(w, http.StatusOK, page)
 }
 
 func (h *MenuHandler) getMenuItemById(w http.ResponseWriter, r *http.Request) {
@@ -131,6 +206,8 @@ func (h *MenuHandler) updateMenuItemById(w http.ResponseWriter, r *http.Request)
 		if errors.Is(err, repository.ErrNotFound) {
 			h.Logger.Error("Menu item not found", "id", id, "error", err)
 			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("menu item \"%s\" not found", id))
+		} else if errors.Is(err, service.ErrInvalidMenuItem) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_menu_item", err)
 		} else {
 			h.Logger.Error("Failed to update menu item", "id", id, "error", err)
 			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not update menu item, please try again later"))
@@ -161,6 +238,106 @@ func (h *MenuHandler) deleteMenuItemById(w http.ResponseWriter, r *http.Request)
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Menu item deleted successfully"})
 }
 
+func (h *MenuHandler) restoreMenuItemById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	err := h.Service.RestoreMenuItemById(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("menu item \"%s\" not found", id))
+		} else if errors.Is(err, service.ErrInvalidMenuItem) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_menu_item", err)
+		} else {
+			h.Logger.Error("Failed to restore menu item", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not restore menu item, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Restored menu item", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Menu item restored successfully"})
+}
+
+func (h *MenuHandler) getMenuItemCost(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	costs, err := h.Service.GetMenuItemCost(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("menu item \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to cost menu item", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not work out the cost of the menu item, please try again later"))
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, costs)
+}
+
+func (h *MenuHandler) getPriceHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	changes, err := h.Service.GetPriceHistory(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("menu item \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to fetch price history", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve price history, please try again later"))
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, changes)
+}
+
+func (h *MenuHandler) schedulePriceChange(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var payload models.PriceChangePayload
+
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		h.Logger.Error("Failed to parse price change request", "id", id, "error", err)
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		return
+	}
+
+	change, err := h.Service.SchedulePriceChange(r.Context(), id, payload)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("menu item \"%s\" not found", id))
+		} else if errors.Is(err, service.ErrInvalidPriceChange) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_price_change", err)
+		} else {
+			h.Logger.Error("Failed to schedule price change", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not schedule price change, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Scheduled price change", "id", id, "change", change.ChangeID, "effective_at", change.EffectiveAt)
+	utils.WriteJSON(w, http.StatusCreated, change)
+}
+
+func (h *MenuHandler) cancelPriceChange(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	changeID := r.PathValue("changeId")
+
+	err := h.Service.CancelPriceChange(r.Context(), id, changeID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("price change \"%s\" not found", changeID))
+		} else if errors.Is(err, service.ErrIllegalTransition) {
+			utils.WriteErrorCode(w, http.StatusConflict, "illegal_transition", err)
+		} else {
+			h.Logger.Error("Failed to cancel price change", "id", id, "change", changeID, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not cancel price change, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Cancelled price change", "id", id, "change", changeID)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Price change cancelled successfully"})
+}
+
 func validateMenuItem(item models.MenuItem) error {
 	if item.ProductId == "" {
 		return errors.New("product ID cannot be empty")
@@ -168,19 +345,186 @@ func validateMenuItem(item models.MenuItem) error {
 	if item.Name == "" {
 		return errors.New("name cannot be empty")
 	}
-	if item.Price <= 0 {
-		return errors.New("price must be greater than zero")
+	if err := validateSchedule(item.Schedule); err != nil {
+		return err
+	}
+	if len(item.Components) > 0 {
+		return validateBundle(item)
 	}
-	if len(item.Ingredients) == 0 {
+	if err := validateModifierGroups(item); err != nil {
+		return err
+	}
+	if len(item.Variants) == 0 {
+		if item.Price <= 0 {
+			return errors.New("price must be greater than zero")
+		}
+		return validateRecipe(item.Ingredients)
+	}
+
+	for i, variant := range item.Variants {
+		if variant.VariantID == "" {
+			return errors.New("variant ID cannot be empty")
+		}
+		if slices.ContainsFunc(item.Variants[:i], func(other models.MenuItemVariant) bool { return other.VariantID == variant.VariantID }) {
+			return fmt.Errorf("variant %q is listed more than once", variant.VariantID)
+		}
+		if variant.Name == "" {
+			return errors.New("variant name cannot be empty")
+		}
+		if variant.Price <= 0 {
+			return fmt.Errorf("price of variant %q must be greater than zero", variant.VariantID)
+		}
+		if err := validateRecipe(variant.Ingredients); err != nil {
+			return fmt.Errorf("variant %q: %w", variant.VariantID, err)
+		}
+	}
+	return nil
+}
+
+func validateRecipe(ingredients []models.MenuItemIngredient) error {
+	if len(ingredients) == 0 {
 		return errors.New("menu item must have at least one ingredient")
 	}
-	for _, ingredient := range item.Ingredients {
+	for _, ingredient := range ingredients {
 		if ingredient.IngredientID == "" {
 			return errors.New("ingredient ID cannot be empty")
 		}
 		if ingredient.Quantity <= 0 {
 			return errors.New("ingredient quantity must be greater than zero")
 		}
+		if ingredient.Unit == "" {
+			return fmt.Errorf("unit of ingredient %q cannot be empty", ingredient.IngredientID)
+		}
+		if _, err := units.Parse(ingredient.Unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateModifierGroups(item models.MenuItem) error {
+	inRecipe := func(id string) bool {
+		has := func(ingredient models.MenuItemIngredient) bool { return ingredient.IngredientID == id }
+		if slices.ContainsFunc(item.Ingredients, has) {
+			return true
+		}
+		return slices.ContainsFunc(item.Variants, func(variant models.MenuItemVariant) bool {
+			return slices.ContainsFunc(variant.Ingredients, has)
+		})
+	}
+
+	for i, group := range item.ModifierGroups {
+		if group.GroupID == "" {
+			return errors.New("modifier group ID cannot be empty")
+		}
+		if slices.ContainsFunc(item.ModifierGroups[:i], func(other models.ModifierGroup) bool { return other.GroupID == group.GroupID }) {
+			return fmt.Errorf("modifier group %q is listed more than once", group.GroupID)
+		}
+		if group.Name == "" {
+			return errors.New("modifier group name cannot be empty")
+		}
+		if len(group.Modifiers) == 0 {
+			return fmt.Errorf("modifier group %q must have at least one modifier", group.GroupID)
+		}
+		if group.MaxSelections < 0 {
+			return fmt.Errorf("max selections of modifier group %q cannot be negative", group.GroupID)
+		}
+		for j, modifier := range group.Modifiers {
+			if modifier.ModifierID == "" {
+				return errors.New("modifier ID cannot be empty")
+			}
+			if slices.ContainsFunc(group.Modifiers[:j], func(other models.Modifier) bool { return other.ModifierID == modifier.ModifierID }) {
+				return fmt.Errorf("modifier %q is listed more than once in %q", modifier.ModifierID, group.GroupID)
+			}
+			if modifier.Name == "" {
+				return errors.New("modifier name cannot be empty")
+			}
+			for _, change := range modifier.Ingredients {
+				if change.IngredientID == "" && change.Replaces == "" {
+					return fmt.Errorf("ingredient of modifier %q must name an ingredient to add or replace", modifier.ModifierID)
+				}
+				if change.Replaces == "" && change.Quantity <= 0 {
+					return fmt.Errorf("ingredient quantity of modifier %q must be greater than zero", modifier.ModifierID)
+				}
+				if change.Quantity < 0 {
+					return fmt.Errorf("ingredient quantity of modifier %q cannot be negative", modifier.ModifierID)
+				}
+				if change.Replaces != "" && !inRecipe(change.Replaces) {
+					return fmt.Errorf("modifier %q replaces %q, which is not in the recipe", modifier.ModifierID, change.Replaces)
+				}
+				// a substitute without a quantity takes the quantity and unit of the ingredient it replaces
+				if change.Quantity > 0 && change.Unit == "" {
+					return fmt.Errorf("ingredient of modifier %q must have a unit", modifier.ModifierID)
+				}
+				if change.Unit != "" {
+					if _, err := units.Parse(change.Unit); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateBundle(item models.MenuItem) error {
+	if len(item.Ingredients) > 0 || len(item.Variants) > 0 || len(item.ModifierGroups) > 0 {
+		return errors.New("a bundle can't have its own ingredients, variants or modifier groups")
+	}
+	if item.Price <= 0 {
+		return errors.New("price must be greater than zero")
+	}
+	for i, slot := range item.Components {
+		if slot.SlotID == "" {
+			return errors.New("slot ID cannot be empty")
+		}
+		if slices.ContainsFunc(item.Components[:i], func(other models.BundleSlot) bool { return other.SlotID == slot.SlotID }) {
+			return fmt.Errorf("slot %q is listed more than once", slot.SlotID)
+		}
+		if slot.Name == "" {
+			return errors.New("slot name cannot be empty")
+		}
+		if len(slot.ProductIDs) == 0 {
+			return fmt.Errorf("slot %q must offer at least one product", slot.SlotID)
+		}
+		if slot.Quantity <= 0 {
+			return fmt.Errorf("quantity of slot %q must be greater than zero", slot.SlotID)
+		}
+	}
+	return nil
+}
+
+var weekdays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+func validateSchedule(schedule *models.Schedule) error {
+	if schedule == nil {
+		return nil
+	}
+	for _, day := range schedule.Days {
+		if !slices.Contains(weekdays, day) {
+			return fmt.Errorf("unknown day %q, use one of %s", day, strings.Join(weekdays, ", "))
+		}
+	}
+	for _, window := range schedule.Windows {
+		for _, clock := range []string{window.From, window.To} {
+			if _, err := time.Parse("15:04", clock); err != nil || len(clock) != len("15:04") {
+				return fmt.Errorf("time %q must be in HH:MM format", clock)
+			}
+		}
+		if window.From == window.To {
+			return errors.New("time window cannot be empty")
+		}
+	}
+	for _, date := range []string{schedule.StartDate, schedule.EndDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return fmt.Errorf("date %q must be in YYYY-MM-DD format", date)
+		}
+	}
+	if schedule.StartDate != "" && schedule.EndDate != "" && schedule.StartDate > schedule.EndDate {
+		return errors.New("start date must not be after end date")
 	}
 	return nil
 }
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyPendingTimeout = time.Minute
+)
+
+// IdempotencyStore keeps the records of the requests made with an Idempotency-Key.
+type IdempotencyStore interface {
+	CreateIdempotencyRecord(ctx context.Context, record models.IdempotencyRecord) error
+	GetIdempotencyRecord(ctx context.Context, key string) (models.IdempotencyRecord, error)
+	CompleteIdempotencyRecord(ctx context.Context, record models.IdempotencyRecord) error
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+}
+
+// Idempotency lets clients retry a request safely by sending an Idempotency-Key header. The first request
+// with a key is handled and its response stored for ttl, retries get that response back instead of being
+// handled again. A retry that arrives while the first request is still being handled gets 409, and one with
+// a different body than the first gets 422. Keys are scoped to the user and to the method and path, so it has
+// to wrap the handler inside the auth middleware. Requests of guests are handled without it: there is nothing
+// only they hold to scope their keys by, and the responses, e.g. of placing an order, can carry the order's
+// access token. Responses with a 5xx status aren't stored, the request can be retried with the same key.
+func Idempotency(store IdempotencyStore, ttl time.Duration, logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+			userID := auth.UserIDFromContext(r.Context())
+			if idempotencyKey == "" || userID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(idempotencyKey) > maxIdempotencyKeyLength {
+				utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_idempotency_key", fmt.Errorf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				utils.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := sha256.Sum256(body)
+
+			now := time.Now().UTC()
+			record := models.IdempotencyRecord{
+				Key:         idempotencyRecordKey(r, userID, idempotencyKey),
+				Fingerprint: hex.EncodeToString(fingerprint[:]),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(idempotencyPendingTimeout),
+			}
+			if err := store.CreateIdempotencyRecord(r.Context(), record); err != nil {
+				if errors.Is(err, repository.ErrConflict) {
+					replay(w, r, store, record)
+					return
+				}
+				logger.Error("Failed to store idempotency key", "error", err)
+				utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to store idempotency key"))
+				return
+			}
+
+			// the request context may have run out by the time the handler returns
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+			defer cancel()
+			completed := false
+			defer func() {
+				if !completed {
+					if err := store.DeleteIdempotencyRecord(ctx, record.Key); err != nil {
+						logger.Error("Failed to release idempotency key", "error", err)
+					}
+				}
+			}()
+
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			if rec.status >= http.StatusInternalServerError {
+				return
+			}
+
+			record.Completed = true
+			record.Status = rec.status
+			record.ContentType = rec.Header().Get("Content-Type")
+			record.Body = rec.body.Bytes()
+			record.ExpiresAt = time.Now().UTC().Add(ttl)
+			if err := store.CompleteIdempotencyRecord(ctx, record); err != nil {
+				logger.Error("Failed to store idempotent response", "error", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// replay answers a request whose key was claimed already with the response stored for it.
+func replay(w http.ResponseWriter, r *http.Request, store IdempotencyStore, request models.IdempotencyRecord) {
+	record, err := store.GetIdempotencyRecord(r.Context(), request.Key)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// the first request failed and released the key in the meantime
+			writeInProgress(w)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to look up idempotency key"))
+		return
+	}
+	if record.Fingerprint != request.Fingerprint {
+		utils.WriteErrorCode(w, http.StatusUnprocessableEntity, "idempotency_key_reused", fmt.Errorf("%s was used for a request with a different body", IdempotencyKeyHeader))
+		return
+	}
+	if !record.Completed {
+		writeInProgress(w)
+		return
+	}
+
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+func writeInProgress(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	utils.WriteErrorCode(w, http.StatusConflict, "idempotency_key_in_progress", fmt.Errorf("a request with this %s is being handled, retry later", IdempotencyKeyHeader))
+}
+
+// idempotencyRecordKey scopes the key to the user who sent the request and to its route.
+func idempotencyRecordKey(r *http.Request, userID, idempotencyKey string) string {
+	route := r.Method + " " + strings.TrimSuffix(r.URL.Path, "/")
+	sum := sha256.Sum256([]byte(userID + "\x00" + route + "\x00" + idempotencyKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder passes the response through to the client and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"cofee-shop-mongo/internal/events"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// streamHeartbeat is how often an idle stream gets a comment, so that proxies don't close it.
+const streamHeartbeat = 15 * time.Second
+
+type OrderEventSubscriber interface {
+	Subscribe(lastEventID string) *events.Subscription
+}
+
+// StreamOrders pushes every order event to the kitchen displays as Server-Sent Events.
+func (h *OrderHandler) StreamOrders(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(w, r) {
+		return
+	}
+	sub := h.Events.Subscribe(r.Header.Get("Last-Event-ID"))
+	defer sub.Close()
+
+	h.stream(w, r, sub, func(models.OrderEvent) bool { return true })
+}
+
+// StreamOrder pushes the events of one order to whoever may see it, so that customers learn when their order is ready.
+// It starts with the order as it is now, read after subscribing so that no change in between is lost.
+func (h *OrderHandler) StreamOrder(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(w, r) {
+		return
+	}
+	sub := h.Events.Subscribe(r.Header.Get("Last-Event-ID"))
+	defer sub.Close()
+
+	id := r.PathValue("id")
+	order, err := h.Service.GetOrderById(withStreamOrderToken(r), id)
+	if err != nil {
+		h.writeOrderError(w, id, err)
+		return
+	}
+	h.stream(w, r, sub, func(event models.OrderEvent) bool { return event.OrderID == id }, models.OrderEvent{
+		Type:         "order.current",
+		OrderID:      order.ProductId,
+		PickupNumber: order.PickupNumber,
+		Status:       order.Status,
+		At:           time.Now().UTC().Format(time.RFC3339),
+		Order:        order,
+	})
+}
+
+// acceptsEventStream reports whether the client asked for an event stream, and answers the request when it didn't.
+func acceptsEventStream(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		utils.WriteError(w, http.StatusNotAcceptable, errors.New("the stream has to be requested with Accept: text/event-stream"))
+		return false
+	}
+	return true
+}
+
+// stream writes the events of sub that keep lets through until the client disconnects, after the initial ones. A
+// client that reconnects with Last-Event-ID first gets the events it missed, or a reset event when they aren't
+// known anymore.
+func (h *OrderHandler) stream(w http.ResponseWriter, r *http.Request, sub *events.Subscription, keep func(models.OrderEvent) bool, initial ...models.OrderEvent) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if sub.Reset {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range initial {
+		writeEvent(w, event)
+	}
+	for _, event := range sub.Missed {
+		if keep(event) {
+			writeEvent(w, event)
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// fell too far behind, the client reconnects and catches up with Last-Event-ID
+				return
+			}
+			if !keep(event) {
+				continue
+			}
+			writeEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes one event in the SSE format, the id is left out for events that aren't on the bus.
+func writeEvent(w http.ResponseWriter, event models.OrderEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
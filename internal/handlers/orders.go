@@ -2,81 +2,151 @@ package handlers
 
 import (
 	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/handlers/middleware"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
 	"cofee-shop-mongo/internal/utils"
 	"cofee-shop-mongo/models"
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 )
 
 type OrderService interface {
-	CreateOrder(ctx context.Context, item models.Order) (string, error)
-	GetAllOrders(ctx context.Context) ([]models.Order, error)
+	CreateOrder(ctx context.Context, item models.Order) (models.Order, string, error)
+	GetAllOrders(ctx context.Context, filter models.OrderFilter, query models.ListQuery) (models.Page[models.Order], error)
 	GetOrderById(ctx context.Context, OrderId string) (models.Order, error)
 	UpdateOrderById(ctx context.Context, OrderId string, item models.Order) error
 	DeleteOrderById(ctx context.Context, OrderId string) error
 	CloseOrderById(ctx context.Context, OrderId string) error
+	TransitionOrder(ctx context.Context, OrderId, status string) (models.Order, error)
+	RefundOrder(ctx context.Context, OrderId string, payload models.RefundPayload) (models.Order, error)
+	GetPickupBoard(ctx context.Context) (models.PickupBoard, error)
 }
 
 type OrderHandler struct {
 	Service OrderService
-	Logger  *slog.Logger
+	Events  OrderEventSubscriber
+	// Idempotent wraps the endpoints that change orders, so that clients can retry them with an Idempotency-Key.
+	Idempotent middleware.Middleware
+	Logger     *slog.Logger
 }
 
-func NewOrderHandler(orderService OrderService, logger *slog.Logger) *OrderHandler {
-	return &OrderHandler{orderService, logger}
+func NewOrderHandler(orderService OrderService, events OrderEventSubscriber, idempotent middleware.Middleware, logger *slog.Logger) *OrderHandler {
+	return &OrderHandler{orderService, events, idempotent, logger}
 }
 
 func (h *OrderHandler) RegisterEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /orders", h.CreateOrder)
-	mux.HandleFunc("POST /orders/", h.CreateOrder)
+	mux.HandleFunc("POST /orders", auth.WithOptionalJWTAuth(h.idempotent(h.CreateOrder)))
+	mux.HandleFunc("POST /orders/", auth.WithOptionalJWTAuth(h.idempotent(h.CreateOrder)))
 
-	mux.HandleFunc("GET /orders", h.GetAllOrders)
-	mux.HandleFunc("GET /orders/", h.GetAllOrders)
+	mux.HandleFunc("GET /orders", auth.WithJWTAuth(models.ClientAccess, h.GetAllOrders))
+	mux.HandleFunc("GET /orders/", auth.WithJWTAuth(models.ClientAccess, h.GetAllOrders))
 
-	mux.HandleFunc("GET /orders/{id}", h.GetOrderById)
-	mux.HandleFunc("GET /orders/{id}/", h.GetOrderById)
+	mux.HandleFunc("GET /orders/pickup-board", h.GetPickupBoard)
+	mux.HandleFunc("GET /orders/pickup-board/{$}", h.GetPickupBoard)
 
-	mux.HandleFunc("PUT /orders/{id}", h.UpdateOrderById)
-	mux.HandleFunc("PUT /orders/{id}/", h.UpdateOrderById)
+	mux.HandleFunc("GET /orders/{id}", auth.WithOptionalJWTAuth(h.GetOrderById))
+	mux.HandleFunc("GET /orders/{id}/", auth.WithOptionalJWTAuth(h.GetOrderById))
 
-	mux.HandleFunc("DELETE /orders/{id}", h.DeleteOrderById)
-	mux.HandleFunc("DELETE /orders/{id}/", h.DeleteOrderById)
+	mux.HandleFunc("PUT /orders/{id}", auth.WithJWTAuth(models.StaffAccess, h.UpdateOrderById))
+	mux.HandleFunc("PUT /orders/{id}/", auth.WithJWTAuth(models.StaffAccess, h.UpdateOrderById))
 
-	mux.HandleFunc("POST /orders/{id}/close", auth.WithJWTAuth(models.StaffAccess, h.CloseOrderById))
-	mux.HandleFunc("POST /orders/{id}/close/", auth.WithJWTAuth(models.StaffAccess, h.CloseOrderById))
+	mux.HandleFunc("DELETE /orders/{id}", auth.WithJWTAuth(models.StaffAccess, h.DeleteOrderById))
+	mux.HandleFunc("DELETE /orders/{id}/", auth.WithJWTAuth(models.StaffAccess, h.DeleteOrderById))
+
+	mux.HandleFunc("POST /orders/{id}/close", auth.WithJWTAuth(models.StaffAccess, h.idempotent(h.CloseOrderById)))
+	mux.HandleFunc("POST /orders/{id}/close/", auth.WithJWTAuth(models.StaffAccess, h.idempotent(h.CloseOrderById)))
+
+	mux.HandleFunc("POST /orders/{id}/transition", auth.WithJWTAuth(models.ClientAccess, h.idempotent(h.TransitionOrder)))
+	mux.HandleFunc("POST /orders/{id}/transition/", auth.WithJWTAuth(models.ClientAccess, h.idempotent(h.TransitionOrder)))
+
+	mux.HandleFunc("POST /orders/{id}/cancel", auth.WithOptionalJWTAuth(h.idempotent(h.CancelOrder)))
+	mux.HandleFunc("POST /orders/{id}/cancel/", auth.WithOptionalJWTAuth(h.idempotent(h.CancelOrder)))
+
+	mux.HandleFunc("POST /orders/{id}/refund", auth.WithJWTAuth(models.StaffAccess, h.idempotent(h.RefundOrder)))
+	mux.HandleFunc("POST /orders/{id}/refund/", auth.WithJWTAuth(models.StaffAccess, h.idempotent(h.RefundOrder)))
+}
+
+// RegisterStreamEndpoints registers the order event streams. They stay open until the client disconnects, so
+// they go on a mux that isn't wrapped in the request timeout.
+func (h *OrderHandler) RegisterStreamEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("GET /orders/stream", auth.WithJWTAuth(models.StaffAccess, h.StreamOrders))
+	mux.HandleFunc("GET /orders/stream/{$}", auth.WithJWTAuth(models.StaffAccess, h.StreamOrders))
+
+	mux.HandleFunc("GET /orders/{id}/stream", auth.WithOptionalJWTAuth(h.StreamOrder))
+	mux.HandleFunc("GET /orders/{id}/stream/", auth.WithOptionalJWTAuth(h.StreamOrder))
+}
+
+func (h *OrderHandler) idempotent(fn http.HandlerFunc) http.HandlerFunc {
+	return h.Idempotent(fn).ServeHTTP
 }
 
 func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	var order models.Order
 	err := utils.ParseJSON(r, &order)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	_, err = h.Service.CreateOrder(r.Context(), order)
+	created, accessToken, err := h.Service.CreateOrder(r.Context(), order)
 	if err != nil {
+		h.writeOrderError(w, "", err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{
+		"message":       "new item was created successfully",
+		"id":            created.ProductId,
+		"pickup_number": created.PickupNumber,
+		"access_token":  accessToken,
+	})
+}
+
+// GetPickupBoard serves the screen customers wait in front of, it only shows pickup numbers.
+func (h *OrderHandler) GetPickupBoard(w http.ResponseWriter, r *http.Request) {
+	board, err := h.Service.GetPickupBoard(r.Context())
+	if err != nil {
+		h.Logger.Error("Failed to get pickup board", "error", err)
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
-	w.Write([]byte("new item was created successfully"))
+	utils.WriteJSON(w, http.StatusOK, board)
 }
 
 func (h *OrderHandler) GetAllOrders(w http.ResponseWriter, r *http.Request) {
-	items, err := h.Service.GetAllOrders(r.Context())
+	query, err := utils.ParseListQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := models.OrderFilter{
+		Status:       r.URL.Query().Get("status"),
+		CustomerName: r.URL.Query().Get("customer"),
+	}
+	if filter.From, filter.To, err = utils.ParseDateRange(r); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := h.Service.GetAllOrders(r.Context(), filter, query)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_query", err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, items)
+	utils.WriteJSON(w, http.StatusOK, page)
 }
 
 func (h *OrderHandler) GetOrderById(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	order, err := h.Service.GetOrderById(r.Context(), id)
+	order, err := h.Service.GetOrderById(withOrderToken(r), id)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		h.writeOrderError(w, id, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, order)
@@ -94,7 +164,7 @@ func (h *OrderHandler) UpdateOrderById(w http.ResponseWriter, r *http.Request) {
 
 	err = h.Service.UpdateOrderById(r.Context(), id, updatedOrder)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		h.writeOrderError(w, id, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, updatedOrder)
@@ -114,8 +184,111 @@ func (h *OrderHandler) CloseOrderById(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	err := h.Service.CloseOrderById(r.Context(), id)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		h.writeOrderError(w, id, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
+
+func (h *OrderHandler) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var payload models.OrderTransitionPayload
+
+	err := utils.ParseJSON(r, &payload)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.Status == "" {
+		utils.WriteErrorCode(w, http.StatusBadRequest, "unknown_status", errors.New("status cannot be empty"))
+		return
+	}
+
+	order, err := h.Service.TransitionOrder(withOrderToken(r), id, payload.Status)
+	if err != nil {
+		h.writeOrderError(w, id, err)
+		return
+	}
+
+	h.Logger.Info("Order status changed", "id", id, "status", order.Status)
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	order, err := h.Service.TransitionOrder(withOrderToken(r), id, models.OrderStatusCancelled)
+	if err != nil {
+		h.writeOrderError(w, id, err)
+		return
+	}
+
+	h.Logger.Info("Order cancelled", "id", id)
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+func (h *OrderHandler) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var payload models.RefundPayload
+
+	// an empty body is a full refund
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	order, err := h.Service.RefundOrder(r.Context(), id, payload)
+	if err != nil {
+		h.writeOrderError(w, id, err)
+		return
+	}
+
+	h.Logger.Info("Order refunded", "id", id, "refunded_amount", order.RefundedAmount)
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+// withOrderToken passes the order access token a guest sent in the X-Order-Token header on to the service.
+// It isn't taken from the URL, which ends up in logs and browser history.
+func withOrderToken(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), auth.OrderTokenKey, r.Header.Get("X-Order-Token"))
+}
+
+// withStreamOrderToken is withOrderToken for the event stream of an order, which also takes the token query
+// parameter since EventSource can't send headers.
+func withStreamOrderToken(r *http.Request) context.Context {
+	token := r.Header.Get("X-Order-Token")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return context.WithValue(r.Context(), auth.OrderTokenKey, token)
+}
+
+// writeOrderError maps errors from the order lifecycle onto status codes, with a
+// machine-readable code for clients that need to react to a specific failure.
+func (h *OrderHandler) writeOrderError(w http.ResponseWriter, id string, err error) {
+	var outOfStock *service.OutOfStockError
+	switch {
+	case errors.As(err, &outOfStock):
+		utils.WriteErrorCode(w, http.StatusConflict, "out_of_stock", outOfStock)
+	case errors.Is(err, repository.ErrNotFound):
+		utils.WriteErrorCode(w, http.StatusNotFound, "not_found", err)
+	case errors.Is(err, service.ErrUnknownStatus):
+		utils.WriteErrorCode(w, http.StatusBadRequest, "unknown_status", err)
+	case errors.Is(err, service.ErrTransitionForbidden):
+		utils.WriteErrorCode(w, http.StatusForbidden, "transition_forbidden", err)
+	case errors.Is(err, service.ErrIllegalTransition):
+		utils.WriteErrorCode(w, http.StatusConflict, "illegal_transition", err)
+	case errors.Is(err, service.ErrInvalidOrder):
+		utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_order", err)
+	case errors.Is(err, service.ErrInvalidRefund):
+		utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_refund", err)
+	case errors.Is(err, service.ErrOrderNotEditable):
+		utils.WriteErrorCode(w, http.StatusConflict, "order_not_editable", err)
+	case errors.Is(err, service.ErrNotEnoughStock):
+		utils.WriteErrorCode(w, http.StatusConflict, "insufficient_stock", err)
+	default:
+		h.Logger.Error("Order request failed", "id", id, "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
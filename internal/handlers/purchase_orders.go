@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+type PurchaseOrderService interface {
+	CreatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) (string, error)
+	GetAllPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter, query models.ListQuery) (models.Page[models.PurchaseOrder], error)
+	GetPurchaseOrderById(ctx context.Context, id string) (models.PurchaseOrder, error)
+	UpdatePurchaseOrderById(ctx context.Context, id string, po models.PurchaseOrder) error
+	DeletePurchaseOrderById(ctx context.Context, id string) error
+	SendPurchaseOrder(ctx context.Context, id string) (models.PurchaseOrder, error)
+	ReceivePurchaseOrder(ctx context.Context, id string, payload models.ReceivePayload) (models.PurchaseOrder, error)
+}
+
+type PurchaseOrderHandler struct {
+	Service PurchaseOrderService
+	Logger  *slog.Logger
+}
+
+func NewPurchaseOrderHandler(service PurchaseOrderService, logger *slog.Logger) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{service, logger}
+}
+
+func (h *PurchaseOrderHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("POST /purchase-orders", auth.WithJWTAuth(models.StaffAccess, h.createPurchaseOrder))
+	mux.HandleFunc("POST /purchase-orders/", auth.WithJWTAuth(models.StaffAccess, h.createPurchaseOrder))
+
+	mux.HandleFunc("GET /purchase-orders", auth.WithJWTAuth(models.StaffAccess, h.getAllPurchaseOrders))
+	mux.HandleFunc("GET /purchase-orders/", auth.WithJWTAuth(models.StaffAccess, h.getAllPurchaseOrders))
+
+	mux.HandleFunc("GET /purchase-orders/{id}", auth.WithJWTAuth(models.StaffAccess, h.getPurchaseOrderById))
+	mux.HandleFunc("GET /purchase-orders/{id}/", auth.WithJWTAuth(models.StaffAccess, h.getPurchaseOrderById))
+
+	mux.HandleFunc("PUT /purchase-orders/{id}", auth.WithJWTAuth(models.StaffAccess, h.updatePurchaseOrderById))
+	mux.HandleFunc("PUT /purchase-orders/{id}/", auth.WithJWTAuth(models.StaffAccess, h.updatePurchaseOrderById))
+
+	mux.HandleFunc("DELETE /purchase-orders/{id}", auth.WithJWTAuth(models.StaffAccess, h.deletePurchaseOrderById))
+	mux.HandleFunc("DELETE /purchase-orders/{id}/", auth.WithJWTAuth(models.StaffAccess, h.deletePurchaseOrderById))
+
+	mux.HandleFunc("POST /purchase-orders/{id}/send", auth.WithJWTAuth(models.StaffAccess, h.sendPurchaseOrder))
+	mux.HandleFunc("POST /purchase-orders/{id}/send/", auth.WithJWTAuth(models.StaffAccess, h.sendPurchaseOrder))
+
+	mux.HandleFunc("POST /purchase-orders/{id}/receive", auth.WithJWTAuth(models.StaffAccess, h.receivePurchaseOrder))
+	mux.HandleFunc("POST /purchase-orders/{id}/receive/", auth.WithJWTAuth(models.StaffAccess, h.receivePurchaseOrder))
+}
+
+func (h *PurchaseOrderHandler) createPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var po models.PurchaseOrder
+
+	if err := utils.ParseJSON(r, &po); err != nil {
+		h.Logger.Error("Failed to parse purchase order request", "error", err)
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		return
+	}
+
+	id, err := h.Service.CreatePurchaseOrder(r.Context(), po)
+	if err != nil {
+		h.writePurchaseOrderError(w, "", err)
+		return
+	}
+
+	h.Logger.Info("New purchase order created", "id", id)
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"message": "New purchase order created successfully", "id": id})
+}
+
+func (h *PurchaseOrderHandler) getAllPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	query, err := utils.ParseListQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := models.PurchaseOrderFilter{
+		Status:     r.URL.Query().Get("status"),
+		SupplierID: r.URL.Query().Get("supplier"),
+	}
+
+	page, err := h.Service.GetAllPurchaseOrders(r.Context(), filter, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_query", err)
+			return
+		}
+		h.Logger.Error("Failed to fetch purchase orders", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve purchase orders, please try again later"))
+		return
+	}
+
+	h.Logger.Info("Fetched purchase orders", "count", len(page.Items))
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+func (h *PurchaseOrderHandler) getPurchaseOrderById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	po, err := h.Service.GetPurchaseOrderById(r.Context(), id)
+	if err != nil {
+		h.writePurchaseOrderError(w, id, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, po)
+}
+
+func (h *PurchaseOrderHandler) updatePurchaseOrderById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var po models.PurchaseOrder
+
+	if err := utils.ParseJSON(r, &po); err != nil {
+		h.Logger.Error("Failed to parse purchase order update request", "id", id, "error", err)
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		return
+	}
+
+	if err := h.Service.UpdatePurchaseOrderById(r.Context(), id, po); err != nil {
+		h.writePurchaseOrderError(w, id, err)
+		return
+	}
+
+	h.Logger.Info("Updated purchase order", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Purchase order updated successfully"})
+}
+
+func (h *PurchaseOrderHandler) deletePurchaseOrderById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := h.Service.DeletePurchaseOrderById(r.Context(), id); err != nil {
+		h.writePurchaseOrderError(w, id, err)
+		return
+	}
+
+	h.Logger.Info("Deleted purchase order", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Purchase order deleted successfully"})
+}
+
+func (h *PurchaseOrderHandler) sendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	po, err := h.Service.SendPurchaseOrder(r.Context(), id)
+	if err != nil {
+		h.writePurchaseOrderError(w, id, err)
+		return
+	}
+
+	h.Logger.Info("Purchase order sent", "id", id)
+	utils.WriteJSON(w, http.StatusOK, po)
+}
+
+func (h *PurchaseOrderHandler) receivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var payload models.ReceivePayload
+
+	// an empty body receives everything that is outstanding
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+			return
+		}
+	}
+
+	po, err := h.Service.ReceivePurchaseOrder(r.Context(), id, payload)
+	if err != nil {
+		h.writePurchaseOrderError(w, id, err)
+		return
+	}
+
+	h.Logger.Info("Purchase order received", "id", id, "status", po.Status)
+	utils.WriteJSON(w, http.StatusOK, po)
+}
+
+func (h *PurchaseOrderHandler) writePurchaseOrderError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("purchase order \"%s\" not found", id))
+	case errors.Is(err, service.ErrInvalidPurchaseOrder):
+		utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_purchase_order", err)
+	case errors.Is(err, service.ErrIllegalTransition):
+		utils.WriteErrorCode(w, http.StatusConflict, "illegal_transition", err)
+	default:
+		h.Logger.Error("Purchase order request failed", "id", id, "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not process purchase order, please try again later"))
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+type SupplierService interface {
+	CreateSupplier(ctx context.Context, supplier models.Supplier) (string, error)
+	GetAllSuppliers(ctx context.Context, filter models.SupplierFilter, query models.ListQuery) (models.Page[models.Supplier], error)
+	GetSupplierById(ctx context.Context, id string) (models.Supplier, error)
+	UpdateSupplierById(ctx context.Context, id string, supplier models.Supplier) error
+	DeleteSupplierById(ctx context.Context, id string) error
+}
+
+type SupplierHandler struct {
+	Service SupplierService
+	Logger  *slog.Logger
+}
+
+func NewSupplierHandler(service SupplierService, logger *slog.Logger) *SupplierHandler {
+	return &SupplierHandler{service, logger}
+}
+
+func (h *SupplierHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("POST /suppliers", auth.WithJWTAuth(models.StaffAccess, h.createSupplier))
+	mux.HandleFunc("POST /suppliers/", auth.WithJWTAuth(models.StaffAccess, h.createSupplier))
+
+	mux.HandleFunc("GET /suppliers", auth.WithJWTAuth(models.StaffAccess, h.getAllSuppliers))
+	mux.HandleFunc("GET /suppliers/", auth.WithJWTAuth(models.StaffAccess, h.getAllSuppliers))
+
+	mux.HandleFunc("GET /suppliers/{id}", auth.WithJWTAuth(models.StaffAccess, h.getSupplierById))
+	mux.HandleFunc("GET /suppliers/{id}/", auth.WithJWTAuth(models.StaffAccess, h.getSupplierById))
+
+	mux.HandleFunc("PUT /suppliers/{id}", auth.WithJWTAuth(models.StaffAccess, h.updateSupplierById))
+	mux.HandleFunc("PUT /suppliers/{id}/", auth.WithJWTAuth(models.StaffAccess, h.updateSupplierById))
+
+	mux.HandleFunc("DELETE /suppliers/{id}", auth.WithJWTAuth(models.StaffAccess, h.deleteSupplierById))
+	mux.HandleFunc("DELETE /suppliers/{id}/", auth.WithJWTAuth(models.StaffAccess, h.deleteSupplierById))
+}
+
+func (h *SupplierHandler) createSupplier(w http.ResponseWriter, r *http.Request) {
+	var supplier models.Supplier
+
+	if err := utils.ParseJSON(r, &supplier); err != nil {
+		h.Logger.Error("Failed to parse supplier request", "error", err)
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		return
+	}
+	if err := validateSupplier(supplier); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id, err := h.Service.CreateSupplier(r.Context(), supplier)
+	if err != nil {
+		h.Logger.Error("Failed to create supplier", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not create supplier, please try again later"))
+		return
+	}
+
+	h.Logger.Info("New supplier created", "id", id)
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"message": "New supplier created successfully", "id": id})
+}
+
+func (h *SupplierHandler) getAllSuppliers(w http.ResponseWriter, r *http.Request) {
+	query, err := utils.ParseListQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := models.SupplierFilter{NamePrefix: r.URL.Query().Get("name")}
+
+	page, err := h.Service.GetAllSuppliers(r.Context(), filter, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_query", err)
+			return
+		}
+		h.Logger.Error("Failed to fetch suppliers", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve suppliers, please try again later"))
+		return
+	}
+
+	h.Logger.Info("Fetched suppliers", "count", len(page.Items))
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+func (h *SupplierHandler) getSupplierById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	supplier, err := h.Service.GetSupplierById(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("supplier \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to fetch supplier", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve supplier, please try again later"))
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, supplier)
+}
+
+func (h *SupplierHandler) updateSupplierById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var supplier models.Supplier
+
+	if err := utils.ParseJSON(r, &supplier); err != nil {
+		h.Logger.Error("Failed to parse supplier update request", "id", id, "error", err)
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		return
+	}
+	if supplier.SupplierID == "" {
+		supplier.SupplierID = id
+	}
+	if supplier.SupplierID != id {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("you cant change supplierID"))
+		return
+	}
+	if err := validateSupplier(supplier); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.Service.UpdateSupplierById(r.Context(), id, supplier)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("supplier \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to update supplier", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not update supplier, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Updated supplier", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Supplier updated successfully"})
+}
+
+func (h *SupplierHandler) deleteSupplierById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	err := h.Service.DeleteSupplierById(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("supplier \"%s\" not found", id))
+		} else {
+			h.Logger.Error("Failed to delete supplier", "id", id, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not delete supplier, please try again later"))
+		}
+		return
+	}
+
+	h.Logger.Info("Deleted supplier", "id", id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Supplier deleted successfully"})
+}
+
+func validateSupplier(supplier models.Supplier) error {
+	if supplier.SupplierID == "" {
+		return errors.New("supplier ID cannot be empty")
+	}
+	if supplier.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	for _, product := range supplier.Products {
+		if product.IngredientID == "" {
+			return errors.New("ingredient ID cannot be empty")
+		}
+		if product.PackSize <= 0 {
+			return errors.New("pack size must be greater than zero")
+		}
+		if product.UnitCost < 0 {
+			return errors.New("unit cost cannot be negative")
+		}
+		if product.LeadTimeDays < 0 {
+			return errors.New("lead time cannot be negative")
+		}
+	}
+	return nil
+}
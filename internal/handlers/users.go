@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
 	"cofee-shop-mongo/internal/utils"
 	"cofee-shop-mongo/models"
 	"context"
@@ -12,7 +13,7 @@ import (
 
 type UserService interface {
 	CreateUser(ctx context.Context, user models.User) (string, error)
-	GetAllUsers(ctx context.Context) ([]models.User, error)
+	GetAllUsers(ctx context.Context, filter models.UserFilter, query models.ListQuery) (models.Page[models.User], error)
 	GetUserById(ctx context.Context, userId string) (models.User, error)
 	UpdateUserById(ctx context.Context, userId string, user models.User) error
 	DeleteUserById(ctx context.Context, userId string) error
@@ -39,7 +40,7 @@ func (h *UserHandler) RegisterEndpoints(mux *http.ServeMux) {
 
 	mux.HandleFunc("PUT /users/{id}", auth.WithJWTAuth(models.AdminAccess, h.updateUserById))
 	mux.HandleFunc("PUT /users/{id}/", auth.WithJWTAuth(models.AdminAccess, h.updateUserById))
-	
+
 	mux.HandleFunc("DELETE /users/{id}", auth.WithJWTAuth(models.AdminAccess, h.deleteUserById))
 	mux.HandleFunc("DELETE /users/{id}/", auth.WithJWTAuth(models.AdminAccess, h.deleteUserById))
 }
@@ -67,12 +68,23 @@ func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) getAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.Service.GetAllUsers(r.Context())
+	query, err := utils.ParseListQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := models.UserFilter{Role: r.URL.Query().Get("role")}
+
+	page, err := h.Service.GetAllUsers(r.Context(), filter, query)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			utils.WriteErrorCode(w, http.StatusBadRequest, "invalid_query", err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not retrieve users, please try again later"))
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, users)
+	utils.WriteJSON(w, http.StatusOK, page)
 }
 
 func (h *UserHandler) getUserById(w http.ResponseWriter, r *http.Request) {
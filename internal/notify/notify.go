@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"cofee-shop-mongo/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LowStockNotifier is told about every item that dropped to its reorder point.
+type LowStockNotifier interface {
+	NotifyLowStock(ctx context.Context, alert models.LowStockAlert) error
+}
+
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	return &LogNotifier{logger}
+}
+
+func (n *LogNotifier) NotifyLowStock(_ context.Context, alert models.LowStockAlert) error {
+	n.logger.Warn("inventory item is low on stock",
+		slog.String("ingredient_id", alert.IngredientID),
+		slog.Float64("quantity", alert.Quantity),
+		slog.Float64("reorder_point", alert.ReorderPoint),
+		slog.Float64("reorder_quantity", alert.ReorderQuantity),
+	)
+	return nil
+}
+
+// WebhookNotifier posts every alert as JSON to a URL. Posts that fail with a network error, a 5xx or a 429
+// response are retried, up to attempts posts in all, waiting backoff before the first retry and twice as
+// long before every next one.
+type WebhookNotifier struct {
+	url      string
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:      url,
+		client:   &http.Client{Timeout: timeout},
+		attempts: 3,
+		backoff:  time.Second,
+	}
+}
+
+func (n *WebhookNotifier) NotifyLowStock(ctx context.Context, alert models.LowStockAlert) error {
+	const op = "notify.NotifyLowStock"
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.attempts {
+			return fmt.Errorf("%s: attempt %d, %w", op, attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: attempt %d, %w, giving up: %w", op, attempt, err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the alert once and tells whether a failure is worth retrying.
+func (n *WebhookNotifier) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Notifiers sends every alert to all of its notifiers and logs the ones that fail.
+type Notifiers struct {
+	notifiers []LowStockNotifier
+	logger    *slog.Logger
+}
+
+func NewNotifiers(logger *slog.Logger, notifiers ...LowStockNotifier) *Notifiers {
+	return &Notifiers{notifiers, logger}
+}
+
+func (n *Notifiers) NotifyLowStock(ctx context.Context, alert models.LowStockAlert) error {
+	for _, notifier := range n.notifiers {
+		if err := notifier.NotifyLowStock(ctx, alert); err != nil {
+			n.logger.Error("failed to send low stock alert", slog.String("ingredient_id", alert.IngredientID), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
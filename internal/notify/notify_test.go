@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testAlert = models.LowStockAlert{
+	IngredientID:    "beans",
+	Name:            "Espresso beans",
+	Quantity:        0.4,
+	Unit:            "kg",
+	ReorderPoint:    0.5,
+	ReorderQuantity: 5,
+	CreatedAt:       "2026-10-17T09:00:00Z",
+}
+
+// newTestNotifier posts to url without waiting between the attempts.
+func newTestNotifier(url string) *WebhookNotifier {
+	n := NewWebhookNotifier(url, time.Second)
+	n.backoff = time.Millisecond
+	return n
+}
+
+func TestWebhookNotifierPostsAlert(t *testing.T) {
+	var got models.LowStockAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method is %s, want POST", r.Method)
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("content type is %q, want application/json", contentType)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode alert: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if err := newTestNotifier(server.URL).NotifyLowStock(context.Background(), testAlert); err != nil {
+		t.Fatalf("NotifyLowStock: %v", err)
+	}
+	if got != testAlert {
+		t.Errorf("posted %+v, want %+v", got, testAlert)
+	}
+}
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantPosts int32
+		wantErr   bool
+	}{
+		{"recovers from server errors", []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK}, 3, false},
+		{"recovers from rate limiting", []int{http.StatusTooManyRequests, http.StatusOK}, 2, false},
+		{"gives up after the last attempt", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK}, 3, true},
+		{"doesn't retry client errors", []int{http.StatusBadRequest, http.StatusOK}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				post := posts.Add(1)
+				w.WriteHeader(tt.statuses[post-1])
+			}))
+			defer server.Close()
+
+			err := newTestNotifier(server.URL).NotifyLowStock(context.Background(), testAlert)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NotifyLowStock error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := posts.Load(); got != tt.wantPosts {
+				t.Errorf("posted %d times, want %d", got, tt.wantPosts)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	if err := newTestNotifier(url).NotifyLowStock(context.Background(), testAlert); err == nil {
+		t.Fatal("NotifyLowStock succeeded without a server")
+	}
+}
+
+func TestWebhookNotifierStopsWhenContextEnds(t *testing.T) {
+	var posts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	n := newTestNotifier(server.URL)
+	n.backoff = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := n.NotifyLowStock(ctx, testAlert); err == nil {
+		t.Fatal("NotifyLowStock succeeded against a failing server")
+	}
+	if got := posts.Load(); got != 1 {
+		t.Errorf("posted %d times, want 1", got)
+	}
+}
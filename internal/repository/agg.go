@@ -16,25 +16,25 @@ func NewReportRepository(db *mongo.Database) *ReportRepository {
 	return &ReportRepository{db}
 }
 
+// soldStatuses are the statuses of the orders that count as sales: the ones handed over to the customer,
+// including those refunded since, whose refunded items are taken off.
+var soldStatuses = bson.A{models.OrderStatusPickedUp, models.OrderStatusRefunded}
+
 func (r *ReportRepository) GetTotalSales(ctx context.Context) (float64, error) {
 	const op = "repository.GetTotalSales"
 	collection := r.db.Collection("orders")
 
 	pipeline := []bson.M{
+		{"$match": bson.M{"status": bson.M{"$in": soldStatuses}}},
 		{"$unwind": "$items"},
-		{
-			"$lookup": bson.M{
-				"from":         "menu",
-				"localField":   "items.product_id",
-				"foreignField": "product_id",
-				"as":           "product",
-			},
-		},
-		{"$unwind": "$product"},
 		{
 			"$project": bson.M{
+				// refunded items are no longer revenue
 				"total_price": bson.M{
-					"$multiply": []interface{}{"$product.price", "$items.quantity"},
+					"$multiply": []interface{}{
+						"$items.unit_price",
+						bson.M{"$subtract": []interface{}{"$items.quantity", bson.M{"$ifNull": []interface{}{"$items.refunded_quantity", 0}}}},
+					},
 				},
 			},
 		},
@@ -104,3 +104,62 @@ func (r *ReportRepository) GetPopularItems(ctx context.Context) ([]models.Popula
 
 	return popularItems, nil
 }
+
+// GetSalesByItem returns the quantity sold and revenue of every menu item. A bundle counts as a sale of
+// each of its components, which get the share of the bundle's price that was allocated to them.
+func (r *ReportRepository) GetSalesByItem(ctx context.Context) ([]models.ItemSales, error) {
+	const op = "repository.GetSalesByItem"
+	collection := r.db.Collection("orders")
+
+	sold := bson.M{"$subtract": bson.A{"$items.quantity", bson.M{"$ifNull": bson.A{"$items.refunded_quantity", 0}}}}
+	pipeline := []bson.M{
+		{"$match": bson.M{"status": bson.M{"$in": soldStatuses}}},
+		{"$unwind": "$items"},
+		{"$project": bson.M{"sales": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$items.components", bson.A{}}}}, 0}},
+			bson.M{"$map": bson.M{
+				"input": "$items.components",
+				"as":    "component",
+				"in": bson.M{
+					"product_id": "$$component.product_id",
+					"quantity":   bson.M{"$multiply": bson.A{"$$component.quantity", sold}},
+					"revenue":    bson.M{"$multiply": bson.A{"$$component.allocated_price", sold}},
+				},
+			}},
+			bson.A{bson.M{
+				"product_id": "$items.product_id",
+				"quantity":   sold,
+				"revenue":    bson.M{"$multiply": bson.A{"$items.unit_price", sold}},
+			}},
+		}}}},
+		{"$unwind": "$sales"},
+		{
+			"$group": bson.M{
+				"_id":      "$sales.product_id",
+				"quantity": bson.M{"$sum": "$sales.quantity"},
+				"revenue":  bson.M{"$sum": "$sales.revenue"},
+			},
+		},
+		{"$sort": bson.M{"revenue": -1}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	sales := []models.ItemSales{}
+	for cursor.Next(ctx) {
+		var item models.ItemSales
+		if err := cursor.Decode(&item); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sales = append(sales, item)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sales, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type CategoryRepository struct {
+	collection *mongo.Collection
+}
+
+func NewCategoryRepository(db *mongo.Database) *CategoryRepository {
+	return &CategoryRepository{
+		collection: db.Collection("categories"),
+	}
+}
+
+func (r *CategoryRepository) CreateCategory(ctx context.Context, category models.Category) (string, error) {
+	const op = "repository.CreateCategory"
+	_, err := r.collection.InsertOne(ctx, category)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return category.CategoryID, nil
+}
+
+// GetAllCategories returns the categories in the order they are shown on the menu.
+func (r *CategoryRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	const op = "repository.GetAllCategories"
+	categories := []models.Category{}
+
+	opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var category models.Category
+		if err := cursor.Decode(&category); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		categories = append(categories, category)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return categories, nil
+}
+
+func (r *CategoryRepository) GetCategoryById(ctx context.Context, id string) (models.Category, error) {
+	const op = "repository.GetCategoryById"
+	var category models.Category
+	err := r.collection.FindOne(ctx, bson.M{"category_id": id}).Decode(&category)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.Category{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return models.Category{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return category, nil
+}
+
+func (r *CategoryRepository) UpdateCategoryById(ctx context.Context, id string, category models.Category) error {
+	const op = "repository.UpdateCategoryById"
+	filter := bson.M{"category_id": id}
+	update := bson.M{"$set": bson.M{
+		"name":     category.Name,
+		"position": category.Position,
+	}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *CategoryRepository) DeleteCategoryById(ctx context.Context, id string) error {
+	const op = "repository.DeleteCategoryById"
+	res, err := r.collection.DeleteOne(ctx, bson.M{"category_id": id})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type CounterRepository struct {
+	collection *mongo.Collection
+}
+
+func NewCounterRepository(db *mongo.Database) *CounterRepository {
+	return &CounterRepository{
+		collection: db.Collection("counters"),
+	}
+}
+
+// NextSequence atomically increments the named counter and returns its new value, the first call for a
+// name returns 1. The counter is removed once expiresAt has passed. Inside a transaction the increment is
+// undone when the transaction aborts.
+func (r *CounterRepository) NextSequence(ctx context.Context, name string, expiresAt time.Time) (int64, error) {
+	const op = "repository.NextSequence"
+	update := bson.M{
+		"$inc":         bson.M{"seq": 1},
+		"$setOnInsert": bson.M{"expires_at": expiresAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": name}, update, opts).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		// two upserts raced to create the counter, the one that lost can increment it now
+		err = r.collection.FindOneAndUpdate(ctx, bson.M{"_id": name}, update, opts).Decode(&counter)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return counter.Seq, nil
+}
@@ -3,5 +3,8 @@ package repository
 import "errors"
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound             = errors.New("not found")
+	ErrInsufficientQuantity = errors.New("insufficient quantity")
+	ErrConflict             = errors.New("document was modified concurrently")
+	ErrInvalidQuery         = errors.New("invalid query")
 )
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type IdempotencyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewIdempotencyRepository(db *mongo.Database) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		collection: db.Collection("idempotency_keys"),
+	}
+}
+
+// CreateIdempotencyRecord stores a new record, the key is its _id so that only one request can claim it.
+// It returns ErrConflict when the key has a record already.
+func (r *IdempotencyRepository) CreateIdempotencyRecord(ctx context.Context, record models.IdempotencyRecord) error {
+	const op = "repository.CreateIdempotencyRecord"
+	_, err := r.collection.InsertOne(ctx, record)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%s: %w", op, ErrConflict)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepository) GetIdempotencyRecord(ctx context.Context, key string) (models.IdempotencyRecord, error) {
+	const op = "repository.GetIdempotencyRecord"
+	var record models.IdempotencyRecord
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&record)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.IdempotencyRecord{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return models.IdempotencyRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return record, nil
+}
+
+// CompleteIdempotencyRecord stores the response of the request that claimed the key.
+func (r *IdempotencyRepository) CompleteIdempotencyRecord(ctx context.Context, record models.IdempotencyRecord) error {
+	const op = "repository.CompleteIdempotencyRecord"
+	update := bson.M{"$set": bson.M{
+		"completed":    true,
+		"status":       record.Status,
+		"content_type": record.ContentType,
+		"body":         record.Body,
+		"expires_at":   record.ExpiresAt,
+	}}
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": record.Key, "completed": false}, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteIdempotencyRecord releases a key that is still pending, so that the request can be retried.
+func (r *IdempotencyRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	const op = "repository.DeleteIdempotencyRecord"
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key, "completed": false})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
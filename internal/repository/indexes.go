@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// indexes are the indexes the lookups, filters and sort orders of the repositories rely on, per collection.
+// Every sort order ends with the id field, which breaks ties for the cursors of list.
+var indexes = map[string][]bson.D{
+	"orders": {
+		{{Key: "created_at", Value: -1}, {Key: "order_id", Value: -1}},
+		{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}, {Key: "order_id", Value: -1}},
+		{{Key: "customer_name", Value: 1}, {Key: "created_at", Value: -1}, {Key: "order_id", Value: -1}},
+		{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "order_id", Value: -1}},
+	},
+	"menu": {
+		{{Key: "product_id", Value: 1}},
+		{{Key: "category_id", Value: 1}, {Key: "product_id", Value: 1}},
+		{{Key: "name", Value: 1}, {Key: "product_id", Value: 1}},
+		{{Key: "price", Value: 1}, {Key: "product_id", Value: 1}},
+	},
+	"inventory": {
+		{{Key: "ingredient_id", Value: 1}},
+		{{Key: "name", Value: 1}, {Key: "ingredient_id", Value: 1}},
+		{{Key: "quantity", Value: 1}, {Key: "ingredient_id", Value: 1}},
+	},
+	"users": {
+		{{Key: "user_id", Value: 1}},
+		{{Key: "email", Value: 1}},
+		{{Key: "username", Value: 1}, {Key: "user_id", Value: 1}},
+		{{Key: "role", Value: 1}, {Key: "username", Value: 1}, {Key: "user_id", Value: 1}},
+	},
+	"inventory_movements": {
+		{{Key: "ingredient_id", Value: 1}, {Key: "created_at", Value: 1}},
+	},
+	"price_changes": {
+		{{Key: "product_id", Value: 1}, {Key: "effective_at", Value: 1}},
+		{{Key: "status", Value: 1}, {Key: "effective_at", Value: 1}},
+	},
+	"suppliers": {
+		{{Key: "supplier_id", Value: 1}},
+		{{Key: "name", Value: 1}, {Key: "supplier_id", Value: 1}},
+		{{Key: "products.ingredient_id", Value: 1}},
+	},
+	"kitchen_tickets": {
+		{{Key: "station", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+		{{Key: "order_id", Value: 1}, {Key: "status", Value: 1}},
+	},
+	"integrity_runs": {
+		{{Key: "started_at", Value: -1}},
+	},
+	"dangling_references": {
+		{{Key: "run_id", Value: 1}, {Key: "collection", Value: 1}, {Key: "seq", Value: 1}},
+		{{Key: "run_id", Value: 1}, {Key: "document_id", Value: 1}, {Key: "seq", Value: 1}},
+	},
+	"purchase_orders": {
+		{{Key: "purchase_order_id", Value: 1}},
+		{{Key: "created_at", Value: -1}, {Key: "purchase_order_id", Value: -1}},
+		{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}, {Key: "purchase_order_id", Value: -1}},
+		{{Key: "supplier_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "purchase_order_id", Value: -1}},
+	},
+}
+
+// constrainedIndexes are the indexes with options: unique ids and dates MongoDB removes documents by
+// once they have passed.
+var constrainedIndexes = map[string][]mongo.IndexModel{
+	"orders": {
+		{Keys: bson.D{{Key: "order_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"kitchen_tickets": {
+		{Keys: bson.D{{Key: "ticket_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"idempotency_keys": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"counters": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"integrity_runs": {
+		{Keys: bson.D{{Key: "run_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// only one check may be running at a time
+		{Keys: bson.D{{Key: "status", Value: 1}}, Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": models.IntegrityCheckRunning})},
+	},
+}
+
+// EnsureIndexes creates the indexes of the repositories, indexes that exist already are left as they are. A
+// collection whose indexes can't be built doesn't stop the others, the errors of all of them are returned.
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	const op = "repository.EnsureIndexes"
+	var errs []error
+	for collection, keys := range indexes {
+		models := make([]mongo.IndexModel, len(keys))
+		for i, key := range keys {
+			models[i] = mongo.IndexModel{Keys: key}
+		}
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s, %w", op, collection, err))
+		}
+	}
+	for collection, models := range constrainedIndexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s, %w", op, collection, err))
+		}
+	}
+	return errors.Join(errs...)
+}
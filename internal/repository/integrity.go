@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type IntegrityRepository struct {
+	db *mongo.Database
+}
+
+func NewIntegrityRepository(db *mongo.Database) *IntegrityRepository {
+	return &IntegrityRepository{db}
+}
+
+// FindDanglingReferences returns every value of check.Field that has no match in the target collection,
+// once per document it appears in.
+func (r *IntegrityRepository) FindDanglingReferences(ctx context.Context, check models.ReferenceCheck) ([]models.DanglingReference, error) {
+	const op = "repository.FindDanglingReferences"
+	collection := r.db.Collection(check.Collection)
+
+	pipeline := []bson.M{
+		{"$project": bson.M{"_id": 0, "document_id": "$" + check.IDField, "reference": "$" + check.Field}},
+	}
+	// every step of the path may be an array, unwinding a plain value leaves it as it is
+	for range strings.Count(check.Field, ".") + 1 {
+		pipeline = append(pipeline, bson.M{"$unwind": "$reference"})
+	}
+	pipeline = append(pipeline,
+		bson.M{"$match": bson.M{"reference": bson.M{"$nin": bson.A{nil, ""}}}},
+		bson.M{"$group": bson.M{"_id": bson.M{"document_id": "$document_id", "reference": "$reference"}}},
+		bson.M{"$lookup": bson.M{
+			"from":         check.Target,
+			"localField":   "_id.reference",
+			"foreignField": check.TargetField,
+			"as":           "targets",
+		}},
+		bson.M{"$match": bson.M{"targets": bson.M{"$size": 0}}},
+		bson.M{"$project": bson.M{"_id": 0, "document_id": "$_id.document_id", "reference": "$_id.reference"}},
+		bson.M{"$sort": bson.M{"document_id": 1, "reference": 1}},
+	)
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	dangling := []models.DanglingReference{}
+	for cursor.Next(ctx) {
+		var result struct {
+			DocumentID string `bson:"document_id"`
+			Reference  string `bson:"reference"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		dangling = append(dangling, models.DanglingReference{
+			Collection: check.Collection,
+			DocumentID: result.DocumentID,
+			Field:      check.Field,
+			Reference:  result.Reference,
+		})
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return dangling, nil
+}
+
+// CreateIntegrityRun records a new integrity check. Only one check can be running at a time, starting another
+// one fails with ErrConflict.
+func (r *IntegrityRepository) CreateIntegrityRun(ctx context.Context, run models.IntegrityRun) error {
+	const op = "repository.CreateIntegrityRun"
+	_, err := r.db.Collection("integrity_runs").InsertOne(ctx, run)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%s: %w", op, ErrConflict)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *IntegrityRepository) UpdateIntegrityRun(ctx context.Context, run models.IntegrityRun) error {
+	const op = "repository.UpdateIntegrityRun"
+	result, err := r.db.Collection("integrity_runs").ReplaceOne(ctx, bson.M{"run_id": run.RunID}, run)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// GetLatestIntegrityRun returns the integrity check started last.
+func (r *IntegrityRepository) GetLatestIntegrityRun(ctx context.Context) (models.IntegrityRun, error) {
+	const op = "repository.GetLatestIntegrityRun"
+	var run models.IntegrityRun
+	opts := options.FindOne().SetSort(bson.D{{Key: "started_at", Value: -1}})
+	err := r.db.Collection("integrity_runs").FindOne(ctx, bson.M{}, opts).Decode(&run)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.IntegrityRun{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return models.IntegrityRun{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return run, nil
+}
+
+// FailStaleIntegrityRuns marks the checks still running that were started before startedBefore as failed,
+// their server stopped before it could record how they ended.
+func (r *IntegrityRepository) FailStaleIntegrityRuns(ctx context.Context, startedBefore, now string) error {
+	const op = "repository.FailStaleIntegrityRuns"
+	_, err := r.db.Collection("integrity_runs").UpdateMany(ctx,
+		bson.M{"status": models.IntegrityCheckRunning, "started_at": bson.M{"$lt": startedBefore}},
+		bson.M{"$set": bson.M{"status": models.IntegrityCheckFailed, "finished_at": now, "error": "the check didn't finish in time"}},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteIntegrityRunsExcept removes every integrity check but runID, with the references they found.
+func (r *IntegrityRepository) DeleteIntegrityRunsExcept(ctx context.Context, runID string) error {
+	const op = "repository.DeleteIntegrityRunsExcept"
+	filter := bson.M{"run_id": bson.M{"$ne": runID}}
+	if _, err := r.db.Collection("dangling_references").DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := r.db.Collection("integrity_runs").DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *IntegrityRepository) AddDanglingReferences(ctx context.Context, references []models.DanglingReference) error {
+	const op = "repository.AddDanglingReferences"
+	if len(references) == 0 {
+		return nil
+	}
+	if _, err := r.db.Collection("dangling_references").InsertMany(ctx, references); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+var danglingReferenceListing = listing{
+	idField:     "seq",
+	sorts:       map[string]string{"collection": "collection", "document_id": "document_id"},
+	defaultSort: "collection",
+}
+
+// ListDanglingReferences returns one page of the dangling references the integrity check runID found.
+func (r *IntegrityRepository) ListDanglingReferences(ctx context.Context, runID string, query models.ListQuery) (models.Page[models.DanglingReference], error) {
+	const op = "repository.ListDanglingReferences"
+	page, err := list[models.DanglingReference](ctx, r.db.Collection("dangling_references"), danglingReferenceListing, bson.M{"run_id": runID}, nil, query)
+	if err != nil {
+		return models.Page[models.DanglingReference]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
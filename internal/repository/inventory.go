@@ -29,11 +29,69 @@ func (r *InventoryRepository) CreateInventoryItem(ctx context.Context, item mode
 	return item.IngredientID, nil
 }
 
-func (r *InventoryRepository) GetAllInventoryItems(ctx context.Context) ([]models.InventoryItem, error) {
-	const op = "repository.GetAllInventoryItems"
-	var items []models.InventoryItem
+var inventoryListing = listing{
+	idField:     "ingredient_id",
+	sorts:       map[string]string{"ingredient_id": "ingredient_id", "name": "name", "quantity": "quantity"},
+	defaultSort: "ingredient_id",
+}
+
+// ListInventoryItems returns one page of the inventory, archived items only when the filter includes them.
+func (r *InventoryRepository) ListInventoryItems(ctx context.Context, filter models.InventoryFilter, query models.ListQuery) (models.Page[models.InventoryItem], error) {
+	const op = "repository.ListInventoryItems"
+
+	match := bson.M{}
+	if !filter.IncludeArchived {
+		match["archived_at"] = bson.M{"$exists": false}
+	}
+	if filter.NamePrefix != "" {
+		match["name"] = prefixFilter(filter.NamePrefix)
+	}
+
+	page, err := list[models.InventoryItem](ctx, r.collection, inventoryListing, match, nil, query)
+	if err != nil {
+		return models.Page[models.InventoryItem]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
+
+// GetInventoryItemsByIds returns the items with the given ids in a single query. Ids that
+// don't exist are left out.
+func (r *InventoryRepository) GetInventoryItemsByIds(ctx context.Context, ids []string) ([]models.InventoryItem, error) {
+	const op = "repository.GetInventoryItemsByIds"
+	items := []models.InventoryItem{}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"ingredient_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item models.InventoryItem
+		if err := cursor.Decode(&item); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		items = append(items, item)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nil
+}
 
-	cursor, err := r.collection.Find(ctx, bson.M{})
+// GetLowStockItems returns the items with a reorder point whose quantity dropped to it. Archived items
+// aren't reordered.
+func (r *InventoryRepository) GetLowStockItems(ctx context.Context) ([]models.InventoryItem, error) {
+	const op = "repository.GetLowStockItems"
+	items := []models.InventoryItem{}
+
+	filter := bson.M{
+		"reorder_point": bson.M{"$gt": 0},
+		"archived_at":   bson.M{"$exists": false},
+		"$expr":         bson.M{"$lte": bson.A{"$quantity", "$reorder_point"}},
+	}
+	cursor, err := r.collection.Find(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -67,13 +125,29 @@ func (r *InventoryRepository) GetInventoryItemById(ctx context.Context, id strin
 	return item, nil
 }
 
-func (r *InventoryRepository) DeleteInventoryItemById(ctx context.Context, id string) error {
-	const op = "repository.DeleteInventoryItemById"
-	res, err := r.collection.DeleteOne(ctx, bson.M{"ingredient_id": id})
+// ArchiveInventoryItemById marks the item as archived at archivedAt, an item that is archived already
+// counts as not found.
+func (r *InventoryRepository) ArchiveInventoryItemById(ctx context.Context, id, archivedAt string) error {
+	const op = "repository.ArchiveInventoryItemById"
+	filter := bson.M{"ingredient_id": id, "archived_at": bson.M{"$exists": false}}
+	res, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"archived_at": archivedAt}})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// RestoreInventoryItemById brings an archived item back.
+func (r *InventoryRepository) RestoreInventoryItemById(ctx context.Context, id string) error {
+	const op = "repository.RestoreInventoryItemById"
+	res, err := r.collection.UpdateOne(ctx, bson.M{"ingredient_id": id}, bson.M{"$unset": bson.M{"archived_at": ""}})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
 		return fmt.Errorf("%s: %w", op, ErrNotFound)
 	}
 	return nil
@@ -83,9 +157,14 @@ func (r *InventoryRepository) UpdateInventoryItemById(ctx context.Context, id st
 	const op = "repository.UpdateInventoryItemById"
 	filter := bson.M{"ingredient_id": id}
 	update := bson.M{"$set": bson.M{
-		"name":     item.Name,
-		"quantity": item.Quantity,
-		"unit":     item.Unit,
+		"name":             item.Name,
+		"quantity":         item.Quantity,
+		"unit":             item.Unit,
+		"reorder_point":    item.ReorderPoint,
+		"reorder_quantity": item.ReorderQuantity,
+		"unit_cost":        item.UnitCost,
+		"allergens":        item.Allergens,
+		"nutrition":        item.Nutrition,
 	}}
 
 	res, err := r.collection.UpdateOne(ctx, filter, update)
@@ -97,3 +176,135 @@ func (r *InventoryRepository) UpdateInventoryItemById(ctx context.Context, id st
 	}
 	return nil
 }
+
+// available is the part of the quantity on hand that is not reserved for an order.
+var available = bson.M{"$subtract": bson.A{"$quantity", bson.M{"$ifNull": bson.A{"$reserved", 0}}}}
+
+// DeductInventoryItemQuantity atomically decrements quantity, but only while at least qty
+// of the item is still available.
+func (r *InventoryRepository) DeductInventoryItemQuantity(ctx context.Context, id string, qty float64) error {
+	const op = "repository.DeductInventoryItemQuantity"
+	filter := bson.M{"ingredient_id": id, "$expr": bson.M{"$gte": bson.A{available, qty}}}
+	update := bson.M{"$inc": bson.M{"quantity": -qty}}
+
+	if err := r.conditionalUpdate(ctx, id, filter, update); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ReserveInventoryItemQuantity holds qty of the item for an order, but only while at least
+// that much is still available.
+func (r *InventoryRepository) ReserveInventoryItemQuantity(ctx context.Context, id string, qty float64) error {
+	const op = "repository.ReserveInventoryItemQuantity"
+	filter := bson.M{"ingredient_id": id, "$expr": bson.M{"$gte": bson.A{available, qty}}}
+	update := bson.M{"$inc": bson.M{"reserved": qty}}
+
+	if err := r.conditionalUpdate(ctx, id, filter, update); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ReleaseInventoryItemReservation gives up to qty of a reservation back to the available stock.
+func (r *InventoryRepository) ReleaseInventoryItemReservation(ctx context.Context, id string, qty float64) error {
+	const op = "repository.ReleaseInventoryItemReservation"
+	filter := bson.M{"ingredient_id": id}
+	update := bson.A{bson.M{"$set": bson.M{"reserved": releasedReservation(qty)}}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// CommitInventoryItemReservation turns qty of a reservation into a deduction of the quantity on hand.
+func (r *InventoryRepository) CommitInventoryItemReservation(ctx context.Context, id string, qty float64) error {
+	const op = "repository.CommitInventoryItemReservation"
+	filter := bson.M{"ingredient_id": id, "quantity": bson.M{"$gte": qty}}
+	update := bson.A{bson.M{"$set": bson.M{
+		"quantity": bson.M{"$subtract": bson.A{"$quantity", qty}},
+		"reserved": releasedReservation(qty),
+	}}}
+
+	if err := r.conditionalUpdate(ctx, id, filter, update); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// releasedReservation lowers the reservation by qty, never letting rounding push it below zero.
+func releasedReservation(qty float64) bson.M {
+	return bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{bson.M{"$ifNull": bson.A{"$reserved", 0}}, qty}}}}
+}
+
+// conditionalUpdate applies update to the item if it matches filter. When nothing matches it tells
+// a missing item (ErrNotFound) apart from one that didn't have enough stock (ErrInsufficientQuantity).
+func (r *InventoryRepository) conditionalUpdate(ctx context.Context, id string, filter bson.M, update interface{}) error {
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		count, err := r.collection.CountDocuments(ctx, bson.M{"ingredient_id": id})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		return ErrInsufficientQuantity
+	}
+	return nil
+}
+
+// RestockInventoryItem adds qty bought at unitCost to the item and updates its unit cost to the
+// weighted average of the stock on hand and the new stock, in a single update.
+func (r *InventoryRepository) RestockInventoryItem(ctx context.Context, id string, qty, unitCost float64) error {
+	const op = "repository.RestockInventoryItem"
+	filter := bson.M{"ingredient_id": id}
+	// stock below zero has no value, so it doesn't weigh in
+	onHand := bson.M{"$max": bson.A{"$quantity", 0}}
+	update := bson.A{bson.M{"$set": bson.M{
+		"unit_cost": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{bson.M{"$add": bson.A{onHand, qty}}, 0}},
+			bson.M{"$divide": bson.A{
+				bson.M{"$add": bson.A{
+					bson.M{"$multiply": bson.A{onHand, bson.M{"$ifNull": bson.A{"$unit_cost", 0}}}},
+					qty * unitCost,
+				}},
+				bson.M{"$add": bson.A{onHand, qty}},
+			}},
+			unitCost,
+		}},
+		"quantity": bson.M{"$add": bson.A{"$quantity", qty}},
+	}}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *InventoryRepository) IncrementInventoryItemQuantity(ctx context.Context, id string, qty float64) error {
+	const op = "repository.IncrementInventoryItemQuantity"
+	filter := bson.M{"ingredient_id": id}
+	update := bson.M{"$inc": bson.M{"quantity": qty}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
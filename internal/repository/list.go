@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// listing says how the documents of a collection are paged. idField is unique and breaks ties between
+// documents that sort equally, sorts maps the sort names clients may ask for to document fields and
+// defaultSort is used when they don't ask for one.
+type listing struct {
+	idField     string
+	sorts       map[string]string
+	defaultSort string
+}
+
+// cursorToken is what a cursor stands for: the sort it was made for and the sort value and id of the
+// last document of the page.
+type cursorToken struct {
+	Sort  string        `bson:"s"`
+	Value bson.RawValue `bson:"v"`
+	ID    bson.RawValue `bson:"id"`
+}
+
+// list returns one page of the documents matching filter, in the order query.Sort asks for. stages run
+// after the filter, before the page is cut, e.g. to add a field to sort by.
+func list[T any](ctx context.Context, collection *mongo.Collection, l listing, filter bson.M, stages []bson.M, query models.ListQuery) (models.Page[T], error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = l.defaultSort
+	}
+	direction := 1
+	if strings.HasPrefix(sort, "-") {
+		direction = -1
+	}
+	field, ok := l.sorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return models.Page[T]{}, fmt.Errorf("%w: can't sort by %q", ErrInvalidQuery, strings.TrimPrefix(sort, "-"))
+	}
+
+	order := bson.D{{Key: field, Value: direction}}
+	if field != l.idField {
+		order = append(order, bson.E{Key: l.idField, Value: direction})
+	}
+	pipeline := append([]bson.M{{"$match": filter}}, stages...)
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor, sort)
+		if err != nil {
+			return models.Page[T]{}, err
+		}
+		pipeline = append(pipeline, bson.M{"$match": afterCursor(field, l.idField, direction, after)})
+	}
+	pipeline = append(pipeline, bson.M{"$sort": order}, bson.M{"$limit": query.Limit + 1})
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return models.Page[T]{}, err
+	}
+	defer cursor.Close(ctx)
+
+	page := models.Page[T]{Items: []T{}}
+	var last bson.Raw
+	for cursor.Next(ctx) {
+		if len(page.Items) == query.Limit {
+			// there is one more document, so the page gets a cursor to continue from
+			page.NextCursor, err = encodeCursor(last, sort, field, l.idField)
+			if err != nil {
+				return models.Page[T]{}, err
+			}
+			break
+		}
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return models.Page[T]{}, err
+		}
+		page.Items = append(page.Items, item)
+		last = append(last[:0], cursor.Current...)
+	}
+	if err := cursor.Err(); err != nil {
+		return models.Page[T]{}, err
+	}
+	return page, nil
+}
+
+// afterCursor matches the documents that come after the cursor in the sort order.
+func afterCursor(field, idField string, direction int, after cursorToken) bson.M {
+	op := "$gt"
+	if direction < 0 {
+		op = "$lt"
+	}
+	if field == idField {
+		return bson.M{idField: bson.M{op: after.ID}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: after.Value}},
+		bson.M{field: after.Value, idField: bson.M{op: after.ID}},
+	}}
+}
+
+func encodeCursor(last bson.Raw, sort, field, idField string) (string, error) {
+	token := cursorToken{Sort: sort, Value: bson.RawValue{Type: bson.TypeNull}}
+	if value, err := last.LookupErr(strings.Split(field, ".")...); err == nil {
+		token.Value = value
+	}
+	id, err := last.LookupErr(idField)
+	if err != nil {
+		return "", err
+	}
+	token.ID = id
+	data, err := bson.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor, sort string) (cursorToken, error) {
+	var token cursorToken
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return cursorToken{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if err := bson.Unmarshal(data, &token); err != nil {
+		return cursorToken{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if token.Sort != sort {
+		return cursorToken{}, fmt.Errorf("%w: the cursor was made for another sort", ErrInvalidQuery)
+	}
+	return token, nil
+}
+
+// prefixFilter matches the values that start with prefix, it can use an index on the field.
+func prefixFilter(prefix string) bson.M {
+	return bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -29,11 +30,16 @@ func (r *MenuRepository) CreateMenuItem(ctx context.Context, item models.MenuIte
 	return item.ProductId, nil
 }
 
-func (r *MenuRepository) GetAllMenuItems(ctx context.Context) ([]models.MenuItem, error) {
+// GetAllMenuItems returns the menu items, archived ones only when includeArchived is set.
+func (r *MenuRepository) GetAllMenuItems(ctx context.Context, includeArchived bool) ([]models.MenuItem, error) {
 	const op = "repository.GetAllMenuItems"
 	var items []models.MenuItem
 
-	cursor, err := r.collection.Find(ctx, bson.M{})
+	filter := bson.M{}
+	if !includeArchived {
+		filter["archived_at"] = bson.M{"$exists": false}
+	}
+	cursor, err := r.collection.Find(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -54,6 +60,51 @@ func (r *MenuRepository) GetAllMenuItems(ctx context.Context) ([]models.MenuItem
 	return items, nil
 }
 
+var menuListing = listing{
+	idField:     "product_id",
+	sorts:       map[string]string{"category": "category_position", "name": "name", "price": "price", "product_id": "product_id"},
+	defaultSort: "category",
+}
+
+// ListMenuItems returns one page of the menu items matching the filter. By default they are grouped by
+// category, in the order of the categories' position, and items without a category come last.
+func (r *MenuRepository) ListMenuItems(ctx context.Context, filter models.MenuFilter, query models.ListQuery) (models.Page[models.MenuItem], error) {
+	const op = "repository.ListMenuItems"
+
+	match := bson.M{}
+	if !filter.IncludeArchived {
+		match["archived_at"] = bson.M{"$exists": false}
+	}
+	if filter.CategoryID != "" {
+		match["category_id"] = filter.CategoryID
+	}
+	price := bson.M{}
+	if filter.MinPrice > 0 {
+		price["$gte"] = filter.MinPrice
+	}
+	if filter.MaxPrice > 0 {
+		price["$lte"] = filter.MaxPrice
+	}
+	if len(price) > 0 {
+		// items with variants are priced per variant, any variant in the range will do
+		match["$or"] = bson.A{
+			bson.M{"price": price},
+			bson.M{"variants": bson.M{"$elemMatch": bson.M{"price": price}}},
+		}
+	}
+	stages := []bson.M{
+		{"$lookup": bson.M{"from": "categories", "localField": "category_id", "foreignField": "category_id", "as": "category"}},
+		{"$addFields": bson.M{"category_position": bson.M{"$ifNull": bson.A{bson.M{"$first": "$category.position"}, math.MaxInt32}}}},
+		{"$project": bson.M{"category": 0}},
+	}
+
+	page, err := list[models.MenuItem](ctx, r.collection, menuListing, match, stages, query)
+	if err != nil {
+		return models.Page[models.MenuItem]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
+
 func (r *MenuRepository) GetMenuItemById(ctx context.Context, id string) (models.MenuItem, error) {
 	const op = "repository.GetMenuItemById"
 	var item models.MenuItem
@@ -67,14 +118,46 @@ func (r *MenuRepository) GetMenuItemById(ctx context.Context, id string) (models
 	return item, nil
 }
 
+// GetMenuItemsByIds returns the menu items with the given ids in a single query. Ids that
+// don't exist are left out.
+func (r *MenuRepository) GetMenuItemsByIds(ctx context.Context, ids []string) ([]models.MenuItem, error) {
+	const op = "repository.GetMenuItemsByIds"
+	items := []models.MenuItem{}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"product_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item models.MenuItem
+		if err := cursor.Decode(&item); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		items = append(items, item)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nil
+}
+
 func (r *MenuRepository) UpdateMenuItemById(ctx context.Context, id string, item models.MenuItem) error {
 	const op = "repository.UpdateMenuItemById"
 	filter := bson.M{"product_id": id}
 	update := bson.M{"$set": bson.M{
-		"name":        item.Name,
-		"description": item.Description,
-		"price":       item.Price,
-		"ingredients": item.Ingredients,
+		"name":            item.Name,
+		"description":     item.Description,
+		"category_id":     item.CategoryID,
+		"price":           item.Price,
+		"ingredients":     item.Ingredients,
+		"variants":        item.Variants,
+		"components":      item.Components,
+		"schedule":        item.Schedule,
+		"modifier_groups": item.ModifierGroups,
+		"station":         item.Station,
 	}}
 
 	res, err := r.collection.UpdateOne(ctx, filter, update)
@@ -87,14 +170,94 @@ func (r *MenuRepository) UpdateMenuItemById(ctx context.Context, id string, item
 	return nil
 }
 
-func (r *MenuRepository) DeleteMenuItemById(ctx context.Context, id string) error {
-	const op = "repository.DeleteMenuItemById"
-	res, err := r.collection.DeleteOne(ctx, bson.M{"product_id": id})
+// UpdateMenuItemPrice sets the price of the menu item, or of its variant when variantID is given.
+func (r *MenuRepository) UpdateMenuItemPrice(ctx context.Context, id, variantID string, price float64) error {
+	const op = "repository.UpdateMenuItemPrice"
+	filter := bson.M{"product_id": id}
+	update := bson.M{"$set": bson.M{"price": price}}
+	if variantID != "" {
+		filter["variants.variant_id"] = variantID
+		update = bson.M{"$set": bson.M{"variants.$.price": price}}
+	}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// ArchiveMenuItemById marks the menu item as archived at archivedAt. The document is kept so that
+// past orders can still be looked up, an item that is archived already counts as not found.
+func (r *MenuRepository) ArchiveMenuItemById(ctx context.Context, id, archivedAt string) error {
+	const op = "repository.ArchiveMenuItemById"
+	filter := bson.M{"product_id": id, "archived_at": bson.M{"$exists": false}}
+	res, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"archived_at": archivedAt}})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
 		return fmt.Errorf("%s: %w", op, ErrNotFound)
 	}
 	return nil
 }
+
+// RestoreMenuItemById puts an archived menu item back on the menu.
+func (r *MenuRepository) RestoreMenuItemById(ctx context.Context, id string) error {
+	const op = "repository.RestoreMenuItemById"
+	res, err := r.collection.UpdateOne(ctx, bson.M{"product_id": id}, bson.M{"$unset": bson.M{"archived_at": ""}})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// GetMenuItemsByIngredient returns the menu items that aren't archived and use the ingredient in their
+// recipe, in the recipe of a variant or in a modifier.
+func (r *MenuRepository) GetMenuItemsByIngredient(ctx context.Context, ingredientID string) ([]models.MenuItem, error) {
+	const op = "repository.GetMenuItemsByIngredient"
+	items := []models.MenuItem{}
+
+	filter := bson.M{
+		"archived_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"ingredients.ingredient_id": ingredientID},
+			bson.M{"variants.ingredients.ingredient_id": ingredientID},
+			bson.M{"modifier_groups.modifiers.ingredients.ingredient_id": ingredientID},
+		},
+	}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item models.MenuItem
+		if err := cursor.Decode(&item); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		items = append(items, item)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nil
+}
+
+// CountMenuItemsInCategory returns how many menu items belong to the category.
+func (r *MenuRepository) CountMenuItemsInCategory(ctx context.Context, categoryID string) (int64, error) {
+	const op = "repository.CountMenuItemsInCategory"
+	count, err := r.collection.CountDocuments(ctx, bson.M{"category_id": categoryID})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// migrations bring the documents written by earlier versions up to date. Each one only touches the
+// documents that still need it, so they can run on every start. They run before the indexes are built,
+// which rely on some of them.
+var migrations = []struct {
+	name string
+	run  func(ctx context.Context, db *mongo.Database) error
+}{
+	{"legacy order statuses", migrateOrderStatuses},
+	{"legacy order ids", migrateOrderIDs},
+	{"order price snapshots", backfillOrderPrices},
+	{"recipe units", backfillRecipeUnits},
+}
+
+// Migrate runs the migrations in order and stops at the first one that fails.
+func Migrate(ctx context.Context, db *mongo.Database) error {
+	const op = "repository.Migrate"
+	for _, migration := range migrations {
+		if err := migration.run(ctx, db); err != nil {
+			return fmt.Errorf("%s: %s, %w", op, migration.name, err)
+		}
+	}
+	return nil
+}
+
+// migrateOrderStatuses moves the orders from before the order lifecycle into it: open orders are pending and
+// closed ones were picked up, with their ingredients deducted.
+func migrateOrderStatuses(ctx context.Context, db *mongo.Database) error {
+	orders := db.Collection("orders")
+	_, err := orders.UpdateMany(ctx,
+		bson.M{"status": "open"},
+		bson.M{"$set": bson.M{"status": models.OrderStatusPending}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = orders.UpdateMany(ctx,
+		bson.M{"status": "closed"},
+		bson.M{"$set": bson.M{"status": models.OrderStatusPickedUp, "stock": models.StockDeducted}},
+	)
+	return err
+}
+
+// migrateOrderIDs gives the orders from when clients chose their ids, whose id is empty or taken by an
+// earlier order, an id of their own, so that order ids can be unique. The ids are made from the document's
+// _id and can't clash with the ones the server generates.
+func migrateOrderIDs(ctx context.Context, db *mongo.Database) error {
+	orders := db.Collection("orders")
+
+	cursor, err := orders.Aggregate(ctx, []bson.M{
+		{"$sort": bson.M{"_id": 1}},
+		{"$group": bson.M{"_id": "$order_id", "ids": bson.M{"$push": "$_id"}}},
+		{"$match": bson.M{"$or": bson.A{
+			bson.M{"_id": bson.M{"$in": bson.A{nil, ""}}},
+			bson.M{"ids.1": bson.M{"$exists": true}},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			OrderID string          `bson:"_id"`
+			IDs     []bson.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+		ids := group.IDs
+		if group.OrderID != "" {
+			// the first order keeps the id
+			ids = ids[1:]
+		}
+		for _, id := range ids {
+			_, err := orders.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"order_id": "ORD-LEGACY-" + id.Hex()}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return cursor.Err()
+}
+
+// backfillOrderPrices snapshots the name and price of the items of orders placed before prices were copied
+// onto them, from the menu as it is now, and sets their totals, so that reports count their revenue. Items
+// whose menu item is gone are priced at zero.
+func backfillOrderPrices(ctx context.Context, db *mongo.Database) error {
+	orders := db.Collection("orders")
+	menu := db.Collection("menu")
+
+	cursor, err := orders.Find(ctx, bson.M{"items": bson.M{"$elemMatch": bson.M{"unit_price": bson.M{"$exists": false}}}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	menuItems := make(map[string]models.MenuItem)
+	for cursor.Next(ctx) {
+		var order struct {
+			ID    bson.ObjectID `bson:"_id"`
+			Items []struct {
+				ProductID string   `bson:"product_id"`
+				Quantity  int      `bson:"quantity"`
+				Name      string   `bson:"name"`
+				UnitPrice *float64 `bson:"unit_price"`
+			} `bson:"items"`
+		}
+		if err := cursor.Decode(&order); err != nil {
+			return err
+		}
+
+		set := bson.M{}
+		var subtotal float64
+		for i, item := range order.Items {
+			if item.UnitPrice != nil {
+				subtotal += *item.UnitPrice * float64(item.Quantity)
+				continue
+			}
+			menuItem, ok := menuItems[item.ProductID]
+			if !ok {
+				err := menu.FindOne(ctx, bson.M{"product_id": item.ProductID}).Decode(&menuItem)
+				if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+					return err
+				}
+				menuItems[item.ProductID] = menuItem
+			}
+			set[fmt.Sprintf("items.%d.unit_price", i)] = menuItem.Price
+			if item.Name == "" {
+				set[fmt.Sprintf("items.%d.name", i)] = menuItem.Name
+			}
+			subtotal += menuItem.Price * float64(item.Quantity)
+		}
+		set["subtotal"] = subtotal
+		set["total"] = subtotal
+		if _, err := orders.UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$set": set}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// backfillRecipeUnits gives the recipe lines of menu items written before units were required the unit their
+// ingredient is stocked in, which is what their quantities were taken to be in. Lines of ingredients that
+// aren't stocked are left without one.
+func backfillRecipeUnits(ctx context.Context, db *mongo.Database) error {
+	menu := db.Collection("menu")
+	inventory := db.Collection("inventory")
+
+	noUnit := bson.M{"$elemMatch": bson.M{"unit": bson.M{"$exists": false}}}
+	cursor, err := menu.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"ingredients": noUnit},
+		bson.M{"variants.ingredients": noUnit},
+		bson.M{"modifier_groups.modifiers.ingredients": bson.M{"$elemMatch": bson.M{
+			"ingredient_id": bson.M{"$exists": true},
+			"quantity":      bson.M{"$gt": 0},
+			"unit":          bson.M{"$exists": false},
+		}}},
+	}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	stockedUnits := make(map[string]string)
+	stockedUnit := func(ingredientID string) (string, error) {
+		if unit, ok := stockedUnits[ingredientID]; ok {
+			return unit, nil
+		}
+		var item models.InventoryItem
+		err := inventory.FindOne(ctx, bson.M{"ingredient_id": ingredientID}).Decode(&item)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return "", err
+		}
+		stockedUnits[ingredientID] = item.Unit
+		return item.Unit, nil
+	}
+	pin := func(ingredients []models.MenuItemIngredient) error {
+		for i := range ingredients {
+			if ingredients[i].Unit != "" {
+				continue
+			}
+			unit, err := stockedUnit(ingredients[i].IngredientID)
+			if err != nil {
+				return err
+			}
+			ingredients[i].Unit = unit
+		}
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var item models.MenuItem
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		if err := pin(item.Ingredients); err != nil {
+			return err
+		}
+		for _, variant := range item.Variants {
+			if err := pin(variant.Ingredients); err != nil {
+				return err
+			}
+		}
+		for _, group := range item.ModifierGroups {
+			for _, modifier := range group.Modifiers {
+				for i, change := range modifier.Ingredients {
+					if change.IngredientID == "" || change.Quantity == 0 || change.Unit != "" {
+						continue
+					}
+					if modifier.Ingredients[i].Unit, err = stockedUnit(change.IngredientID); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		set := bson.M{"ingredients": item.Ingredients}
+		if len(item.Variants) > 0 {
+			set["variants"] = item.Variants
+		}
+		if len(item.ModifierGroups) > 0 {
+			set["modifier_groups"] = item.ModifierGroups
+		}
+		if _, err := menu.UpdateOne(ctx, bson.M{"product_id": item.ProductId}, bson.M{"$set": set}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type MovementRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMovementRepository(db *mongo.Database) *MovementRepository {
+	return &MovementRepository{
+		collection: db.Collection("inventory_movements"),
+	}
+}
+
+func (r *MovementRepository) CreateMovement(ctx context.Context, movement models.InventoryMovement) error {
+	const op = "repository.CreateMovement"
+	_, err := r.collection.InsertOne(ctx, movement)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetMovementsByIngredient returns the movements of an ingredient, oldest first. from and to are
+// optional UTC RFC3339 bounds, from inclusive and to exclusive.
+func (r *MovementRepository) GetMovementsByIngredient(ctx context.Context, ingredientID, from, to string) ([]models.InventoryMovement, error) {
+	const op = "repository.GetMovementsByIngredient"
+	movements := []models.InventoryMovement{}
+
+	filter := bson.M{"ingredient_id": ingredientID}
+	createdAt := bson.M{}
+	if from != "" {
+		createdAt["$gte"] = from
+	}
+	if to != "" {
+		createdAt["$lt"] = to
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var movement models.InventoryMovement
+		if err := cursor.Decode(&movement); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		movements = append(movements, movement)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return movements, nil
+}
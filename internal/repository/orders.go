@@ -5,8 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type OrderRepository struct {
@@ -22,37 +25,57 @@ func (r *OrderRepository) CreateOrder(ctx context.Context, order models.Order) (
 	const op = "repository.CreateOrder"
 	_, err := r.collection.InsertOne(ctx, order)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", fmt.Errorf("%s: %w", op, ErrConflict)
+		}
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 	return order.ProductId, nil
 }
 
-func (r *OrderRepository) GetAllOrders(ctx context.Context) ([]models.Order, error) {
-	const op = "repository.GetAllOrders"
-	var orders []models.Order
+var orderListing = listing{
+	idField:     "order_id",
+	sorts:       map[string]string{"created_at": "created_at", "total": "total", "status": "status"},
+	defaultSort: "-created_at",
+}
 
-	cursor, err := r.collection.Find(ctx, bson.D{})
+// ListOrders returns one page of the orders matching the filter, newest first unless query sorts otherwise.
+func (r *OrderRepository) ListOrders(ctx context.Context, filter models.OrderFilter, query models.ListQuery) (models.Page[models.Order], error) {
+	const op = "repository.ListOrders"
 
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	match := bson.M{}
+	if filter.Status != "" {
+		match["status"] = filter.Status
+	}
+	if filter.CustomerName != "" {
+		match["customer_name"] = filter.CustomerName
+	}
+	if filter.CustomerID != "" {
+		match["customer_id"] = filter.CustomerID
+	}
+	createdAt := bson.M{}
+	if filter.From != "" {
+		createdAt["$gte"] = filter.From
+	}
+	if filter.To != "" {
+		createdAt["$lt"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		match["created_at"] = createdAt
 	}
-	defer cursor.Close(ctx)
 
-	for cursor.Next(ctx) {
-		var order models.Order
-		if err = cursor.Decode(&order); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		orders = append(orders, order)
+	page, err := list[models.Order](ctx, r.collection, orderListing, match, nil, query)
+	if err != nil {
+		return models.Page[models.Order]{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return orders, nil
+	return page, nil
 }
 
 func (r *OrderRepository) GetOrderById(ctx context.Context, orderId string) (models.Order, error) {
 	const op = "repository.GetOrderById"
 	var order models.Order
 
-	err := r.collection.FindOne(ctx, bson.D{{"order_id", orderId}}).Decode(&order)
+	err := r.collection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return models.Order{}, fmt.Errorf("%s: %w", op, ErrNotFound)
@@ -64,14 +87,22 @@ func (r *OrderRepository) GetOrderById(ctx context.Context, orderId string) (mod
 	return order, nil
 }
 
-func (r *OrderRepository) UpdateOrderById(ctx context.Context, orderId string, order models.Order) error {
+// UpdateOrderById replaces the items of the order as long as its status and stock are still the given ones, or
+// returns ErrConflict.
+func (r *OrderRepository) UpdateOrderById(ctx context.Context, orderId, status, stock string, order models.Order) error {
 	const op = "repository.UpdateOrderById"
-	filter := bson.M{"order_id": orderId}
+	filter := bson.M{"order_id": orderId, "status": status, "stock": stock}
+	if stock == "" {
+		filter["stock"] = bson.M{"$in": bson.A{nil, ""}}
+	}
 	update := bson.M{"$set": bson.M{
-		"customer_name": order.CustomerName,
-		"items":         order.Items,
-		"status":        order.Status,
-		"created_at":    order.CreatedAt,
+		"customer_name":  order.CustomerName,
+		"items":          order.Items,
+		"subtotal":       order.Subtotal,
+		"total":          order.Total,
+		"stock":          order.Stock,
+		"reserved_until": order.ReservedUntil,
+		"created_at":     order.CreatedAt,
 	}}
 
 	res, err := r.collection.UpdateOne(ctx, filter, update)
@@ -79,14 +110,14 @@ func (r *OrderRepository) UpdateOrderById(ctx context.Context, orderId string, o
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	if res.MatchedCount == 0 {
-		return fmt.Errorf("%s: %w", op, ErrNotFound)
+		return fmt.Errorf("%s: %w", op, ErrConflict)
 	}
 	return nil
 }
 
 func (r *OrderRepository) DeleteOrderById(ctx context.Context, orderId string) error {
 	const op = "repository.DeleteOrderById"
-	res, err := r.collection.DeleteOne(ctx, bson.D{{"order_id", orderId}})
+	res, err := r.collection.DeleteOne(ctx, bson.M{"order_id": orderId})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -95,3 +126,195 @@ func (r *OrderRepository) DeleteOrderById(ctx context.Context, orderId string) e
 	}
 	return nil
 }
+
+// UpdateOrderStatus moves the order from one status to another and appends the change to
+// its status history. It fails with ErrConflict when the order is no longer in the expected
+// status, which makes it safe to use as a guard against two requests changing the same order at once.
+func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, orderId, from string, change models.StatusChange) error {
+	const op = "repository.UpdateOrderStatus"
+	filter := bson.M{"order_id": orderId, "status": from}
+	update := bson.M{
+		"$set":  bson.M{"status": change.Status},
+		"$push": bson.M{"status_history": change},
+	}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrConflict)
+	}
+	return nil
+}
+
+func (r *OrderRepository) UpdateOrderItems(ctx context.Context, orderId string, items []models.OrderItem) error {
+	const op = "repository.UpdateOrderItems"
+	filter := bson.M{"order_id": orderId}
+	update := bson.M{"$set": bson.M{"items": items}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// AddOrderRefund stores the items with their updated refunded quantities and appends the refund.
+func (r *OrderRepository) AddOrderRefund(ctx context.Context, orderId string, items []models.OrderItem, refund models.Refund) error {
+	const op = "repository.AddOrderRefund"
+	filter := bson.M{"order_id": orderId}
+	update := bson.M{
+		"$set":  bson.M{"items": items},
+		"$push": bson.M{"refunds": refund},
+		"$inc":  bson.M{"refunded_amount": refund.Amount},
+	}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// UpdateOrderStock records what happened to the order's ingredients. Like UpdateOrderStatus it
+// fails with ErrConflict when the order's stock state is no longer the expected one.
+func (r *OrderRepository) UpdateOrderStock(ctx context.Context, orderId, from, to string) error {
+	const op = "repository.UpdateOrderStock"
+	filter := bson.M{"order_id": orderId, "stock": from}
+	if from == "" {
+		filter["stock"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+	update := bson.M{"$set": bson.M{"stock": to}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrConflict)
+	}
+	return nil
+}
+
+// GetOrdersWithExpiredReservations returns the orders still holding stock whose reservation ran out
+// before the given UTC RFC3339 time.
+func (r *OrderRepository) GetOrdersWithExpiredReservations(ctx context.Context, before string) ([]models.Order, error) {
+	const op = "repository.GetOrdersWithExpiredReservations"
+	var orders []models.Order
+
+	filter := bson.M{"stock": models.StockReserved, "reserved_until": bson.M{"$lt": before}}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var order models.Order
+		if err = cursor.Decode(&order); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		orders = append(orders, order)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return orders, nil
+}
+
+// GetOrdersByStatus returns the orders in one of the statuses that were placed at or after the given
+// UTC RFC3339 time, oldest first.
+func (r *OrderRepository) GetOrdersByStatus(ctx context.Context, statuses []string, since string) ([]models.Order, error) {
+	const op = "repository.GetOrdersByStatus"
+	orders := []models.Order{}
+
+	filter := bson.M{"status": bson.M{"$in": statuses}, "created_at": bson.M{"$gte": since}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "order_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var order models.Order
+		if err = cursor.Decode(&order); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		orders = append(orders, order)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return orders, nil
+}
+
+// WatchOrderEvents follows the changes of the orders collection with a change stream and calls fn for every
+// order that is placed or moves to another status, from resumeAfter on or from now when it is "". Events are
+// identified by the resume token of their change, which is the same on every server instance. It blocks
+// until ctx is done or the stream fails, and returns the token of the last change it saw so that the
+// caller can resume from there.
+func (r *OrderRepository) WatchOrderEvents(ctx context.Context, resumeAfter string, fn func(models.OrderEvent)) (string, error) {
+	const op = "repository.WatchOrderEvents"
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update"}}}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeAfter != "" {
+		opts.SetResumeAfter(bson.M{"_data": resumeAfter})
+	}
+	stream, err := r.collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return resumeAfter, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var change struct {
+			ID struct {
+				Data string `bson:"_data"`
+			} `bson:"_id"`
+			OperationType     string       `bson:"operationType"`
+			FullDocument      models.Order `bson:"fullDocument"`
+			UpdateDescription struct {
+				UpdatedFields bson.Raw `bson:"updatedFields"`
+			} `bson:"updateDescription"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return resumeAfter, fmt.Errorf("%s: %w", op, err)
+		}
+		resumeAfter = change.ID.Data
+
+		eventType := models.OrderEventCreated
+		if change.OperationType == "update" {
+			if _, err := change.UpdateDescription.UpdatedFields.LookupErr("status"); err != nil {
+				continue
+			}
+			eventType = models.OrderEventStatusChanged
+		}
+		order := change.FullDocument
+		if order.ProductId == "" {
+			// the order was deleted before its change could be looked up
+			continue
+		}
+		fn(models.OrderEvent{
+			ID:           change.ID.Data,
+			Type:         eventType,
+			OrderID:      order.ProductId,
+			PickupNumber: order.PickupNumber,
+			Status:       order.Status,
+			At:           time.Now().UTC().Format(time.RFC3339),
+			Order:        order,
+		})
+	}
+	if err := stream.Err(); err != nil {
+		return resumeAfter, fmt.Errorf("%s: %w", op, err)
+	}
+	return resumeAfter, ctx.Err()
+}
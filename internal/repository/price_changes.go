@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type PriceChangeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPriceChangeRepository(db *mongo.Database) *PriceChangeRepository {
+	return &PriceChangeRepository{
+		collection: db.Collection("price_changes"),
+	}
+}
+
+func (r *PriceChangeRepository) CreatePriceChange(ctx context.Context, change models.PriceChange) (string, error) {
+	const op = "repository.CreatePriceChange"
+	_, err := r.collection.InsertOne(ctx, change)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return change.ChangeID, nil
+}
+
+func (r *PriceChangeRepository) GetPriceChangeById(ctx context.Context, id string) (models.PriceChange, error) {
+	const op = "repository.GetPriceChangeById"
+	var change models.PriceChange
+	err := r.collection.FindOne(ctx, bson.M{"change_id": id}).Decode(&change)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.PriceChange{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return models.PriceChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return change, nil
+}
+
+// GetPriceChangesByProduct returns every price change of the menu item, by the time it takes effect.
+func (r *PriceChangeRepository) GetPriceChangesByProduct(ctx context.Context, productID string) ([]models.PriceChange, error) {
+	const op = "repository.GetPriceChangesByProduct"
+	opts := options.Find().SetSort(bson.D{{Key: "effective_at", Value: 1}, {Key: "_id", Value: 1}})
+	changes, err := r.find(ctx, bson.M{"product_id": productID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return changes, nil
+}
+
+// GetDuePriceChanges returns the scheduled price changes that take effect at or before the UTC RFC3339 time before.
+func (r *PriceChangeRepository) GetDuePriceChanges(ctx context.Context, before string) ([]models.PriceChange, error) {
+	const op = "repository.GetDuePriceChanges"
+	filter := bson.M{"status": models.PriceChangeScheduled, "effective_at": bson.M{"$lte": before}}
+	opts := options.Find().SetSort(bson.D{{Key: "effective_at", Value: 1}, {Key: "_id", Value: 1}})
+	changes, err := r.find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return changes, nil
+}
+
+// UpdatePriceChange overwrites the change, but only while it is still in status from.
+func (r *PriceChangeRepository) UpdatePriceChange(ctx context.Context, id, from string, change models.PriceChange) error {
+	const op = "repository.UpdatePriceChange"
+	filter := bson.M{"change_id": id, "status": from}
+	update := bson.M{"$set": bson.M{
+		"old_price":  change.OldPrice,
+		"status":     change.Status,
+		"applied_at": change.AppliedAt,
+	}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrConflict)
+	}
+	return nil
+}
+
+func (r *PriceChangeRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptionsBuilder) ([]models.PriceChange, error) {
+	changes := []models.PriceChange{}
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var change models.PriceChange
+		if err := cursor.Decode(&change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type PurchaseOrderRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPurchaseOrderRepository(db *mongo.Database) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{
+		collection: db.Collection("purchase_orders"),
+	}
+}
+
+func (r *PurchaseOrderRepository) CreatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) (string, error) {
+	const op = "repository.CreatePurchaseOrder"
+	_, err := r.collection.InsertOne(ctx, po)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return po.PurchaseOrderID, nil
+}
+
+var purchaseOrderListing = listing{
+	idField:     "purchase_order_id",
+	sorts:       map[string]string{"created_at": "created_at", "total": "total", "status": "status"},
+	defaultSort: "-created_at",
+}
+
+// ListPurchaseOrders returns one page of the purchase orders matching the filter, newest first unless
+// query sorts otherwise.
+func (r *PurchaseOrderRepository) ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter, query models.ListQuery) (models.Page[models.PurchaseOrder], error) {
+	const op = "repository.ListPurchaseOrders"
+
+	match := bson.M{}
+	if filter.Status != "" {
+		match["status"] = filter.Status
+	}
+	if filter.SupplierID != "" {
+		match["supplier_id"] = filter.SupplierID
+	}
+
+	page, err := list[models.PurchaseOrder](ctx, r.collection, purchaseOrderListing, match, nil, query)
+	if err != nil {
+		return models.Page[models.PurchaseOrder]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
+
+func (r *PurchaseOrderRepository) GetPurchaseOrderById(ctx context.Context, id string) (models.PurchaseOrder, error) {
+	const op = "repository.GetPurchaseOrderById"
+	var po models.PurchaseOrder
+
+	err := r.collection.FindOne(ctx, bson.M{"purchase_order_id": id}).Decode(&po)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.PurchaseOrder{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return models.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return po, nil
+}
+
+// UpdatePurchaseOrderById overwrites the purchase order, but only while it still has the status
+// it was read with. Otherwise it fails with ErrConflict.
+func (r *PurchaseOrderRepository) UpdatePurchaseOrderById(ctx context.Context, id, status string, po models.PurchaseOrder) error {
+	const op = "repository.UpdatePurchaseOrderById"
+	filter := bson.M{"purchase_order_id": id, "status": status}
+	update := bson.M{"$set": bson.M{
+		"supplier_id": po.SupplierID,
+		"status":      po.Status,
+		"lines":       po.Lines,
+		"total":       po.Total,
+		"sent_at":     po.SentAt,
+		"received_at": po.ReceivedAt,
+	}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrConflict)
+	}
+	return nil
+}
+
+func (r *PurchaseOrderRepository) DeletePurchaseOrderById(ctx context.Context, id, status string) error {
+	const op = "repository.DeletePurchaseOrderById"
+	res, err := r.collection.DeleteOne(ctx, bson.M{"purchase_order_id": id, "status": status})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrConflict)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type SupplierRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSupplierRepository(db *mongo.Database) *SupplierRepository {
+	return &SupplierRepository{
+		collection: db.Collection("suppliers"),
+	}
+}
+
+func (r *SupplierRepository) CreateSupplier(ctx context.Context, supplier models.Supplier) (string, error) {
+	const op = "repository.CreateSupplier"
+	_, err := r.collection.InsertOne(ctx, supplier)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return supplier.SupplierID, nil
+}
+
+var supplierListing = listing{
+	idField:     "supplier_id",
+	sorts:       map[string]string{"supplier_id": "supplier_id", "name": "name"},
+	defaultSort: "name",
+}
+
+// ListSuppliers returns one page of the suppliers.
+func (r *SupplierRepository) ListSuppliers(ctx context.Context, filter models.SupplierFilter, query models.ListQuery) (models.Page[models.Supplier], error) {
+	const op = "repository.ListSuppliers"
+
+	match := bson.M{}
+	if filter.NamePrefix != "" {
+		match["name"] = prefixFilter(filter.NamePrefix)
+	}
+
+	page, err := list[models.Supplier](ctx, r.collection, supplierListing, match, nil, query)
+	if err != nil {
+		return models.Page[models.Supplier]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
+
+func (r *SupplierRepository) GetSupplierById(ctx context.Context, id string) (models.Supplier, error) {
+	const op = "repository.GetSupplierById"
+	var supplier models.Supplier
+
+	err := r.collection.FindOne(ctx, bson.M{"supplier_id": id}).Decode(&supplier)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.Supplier{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return models.Supplier{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return supplier, nil
+}
+
+// GetSuppliersByIngredient returns the suppliers whose catalog has the ingredient.
+func (r *SupplierRepository) GetSuppliersByIngredient(ctx context.Context, ingredientID string) ([]models.Supplier, error) {
+	const op = "repository.GetSuppliersByIngredient"
+	suppliers := []models.Supplier{}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"products.ingredient_id": ingredientID})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &suppliers); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return suppliers, nil
+}
+
+func (r *SupplierRepository) UpdateSupplierById(ctx context.Context, id string, supplier models.Supplier) error {
+	const op = "repository.UpdateSupplierById"
+	filter := bson.M{"supplier_id": id}
+	update := bson.M{"$set": bson.M{
+		"name":     supplier.Name,
+		"email":    supplier.Email,
+		"phone":    supplier.Phone,
+		"products": supplier.Products,
+	}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *SupplierRepository) DeleteSupplierById(ctx context.Context, id string) error {
+	const op = "repository.DeleteSupplierById"
+	res, err := r.collection.DeleteOne(ctx, bson.M{"supplier_id": id})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type TicketRepository struct {
+	collection *mongo.Collection
+}
+
+func NewTicketRepository(db *mongo.Database) *TicketRepository {
+	return &TicketRepository{
+		collection: db.Collection("kitchen_tickets"),
+	}
+}
+
+func (r *TicketRepository) CreateTickets(ctx context.Context, tickets []models.KitchenTicket) error {
+	const op = "repository.CreateTickets"
+	if len(tickets) == 0 {
+		return nil
+	}
+	if _, err := r.collection.InsertMany(ctx, tickets); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%s: %w", op, ErrConflict)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *TicketRepository) GetTicketById(ctx context.Context, id string) (models.KitchenTicket, error) {
+	const op = "repository.GetTicketById"
+	var ticket models.KitchenTicket
+	err := r.collection.FindOne(ctx, bson.M{"ticket_id": id}).Decode(&ticket)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.KitchenTicket{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return models.KitchenTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return ticket, nil
+}
+
+// GetTickets returns the tickets matching the filter, oldest first.
+func (r *TicketRepository) GetTickets(ctx context.Context, filter models.TicketFilter) ([]models.KitchenTicket, error) {
+	const op = "repository.GetTickets"
+	tickets := []models.KitchenTicket{}
+
+	match := bson.M{"status": bson.M{"$in": filter.Statuses}}
+	if filter.Station != "" {
+		match["station"] = filter.Station
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "ticket_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, match, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var ticket models.KitchenTicket
+		if err := cursor.Decode(&ticket); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		tickets = append(tickets, ticket)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tickets, nil
+}
+
+// UpdateTicketStatus moves the ticket from one status to the next and records when. It returns ErrConflict
+// when the ticket isn't in the from status anymore, e.g. because it was bumped at the same time.
+func (r *TicketRepository) UpdateTicketStatus(ctx context.Context, id, from, to, at string) error {
+	const op = "repository.UpdateTicketStatus"
+	set := bson.M{"status": to}
+	switch to {
+	case models.TicketInProgress:
+		set["started_at"] = at
+	case models.TicketDone:
+		set["done_at"] = at
+	}
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"ticket_id": id, "status": from}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, ErrConflict)
+	}
+	return nil
+}
+
+// CountOpenTickets returns how many tickets of the order aren't done yet.
+func (r *TicketRepository) CountOpenTickets(ctx context.Context, orderID string) (int64, error) {
+	const op = "repository.CountOpenTickets"
+	count, err := r.collection.CountDocuments(ctx, bson.M{"order_id": orderID, "status": bson.M{"$ne": models.TicketDone}})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+// DeleteOpenTickets removes the tickets of the order that aren't done, so that the stations stop working on it.
+func (r *TicketRepository) DeleteOpenTickets(ctx context.Context, orderID string) error {
+	const op = "repository.DeleteOpenTickets"
+	_, err := r.collection.DeleteMany(ctx, bson.M{"order_id": orderID, "status": bson.M{"$ne": models.TicketDone}})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteOrderTickets removes every ticket of the order.
+func (r *TicketRepository) DeleteOrderTickets(ctx context.Context, orderID string) error {
+	const op = "repository.DeleteOrderTickets"
+	_, err := r.collection.DeleteMany(ctx, bson.M{"order_id": orderID})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
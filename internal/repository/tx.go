@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type TxManager struct {
+	client *mongo.Client
+}
+
+func NewTxManager(db *mongo.Database) *TxManager {
+	return &TxManager{
+		client: db.Client(),
+	}
+}
+
+type afterCommitKey struct{}
+
+type afterCommitHooks struct {
+	fns []func()
+}
+
+// WithTransaction runs fn inside a MongoDB transaction. Every repository call made
+// with the ctx passed to fn takes part in it, and any error returned by fn aborts it.
+// fn may be retried on transient errors, so it must be safe to run more than once.
+func (m *TxManager) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "repository.WithTransaction"
+	session, err := m.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer session.EndSession(ctx)
+
+	var hooks *afterCommitHooks
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (interface{}, error) {
+		// a retry starts over, so drop the hooks registered by the failed attempt
+		hooks = &afterCommitHooks{}
+		return nil, fn(context.WithValue(ctx, afterCommitKey{}, hooks))
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, hook := range hooks.fns {
+		hook()
+	}
+	return nil
+}
+
+// AfterCommit runs hook once the transaction ctx belongs to has been committed, and never if it
+// is aborted. Outside of a transaction hook runs right away.
+func AfterCommit(ctx context.Context, hook func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks); ok {
+		hooks.fns = append(hooks.fns, hook)
+		return
+	}
+	hook()
+}
@@ -28,25 +28,26 @@ func (r *UserRepository) CreateUser(ctx context.Context, user models.User) (stri
 	return user.Username, nil
 }
 
-func (r *UserRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
-	const op = "repository.GetAllUsers"
-	var users []models.User
+var userListing = listing{
+	idField:     "user_id",
+	sorts:       map[string]string{"user_id": "user_id", "username": "username", "email": "email"},
+	defaultSort: "username",
+}
 
-	cursor, err := r.collection.Find(ctx, bson.M{})
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-	defer cursor.Close(ctx)
+// ListUsers returns one page of the users, of one role when the filter names it.
+func (r *UserRepository) ListUsers(ctx context.Context, filter models.UserFilter, query models.ListQuery) (models.Page[models.User], error) {
+	const op = "repository.ListUsers"
 
-	for cursor.Next(ctx) {
-		var user models.User
-		if err := cursor.Decode(&user); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		users = append(users, user)
+	match := bson.M{}
+	if filter.Role != "" {
+		match["role"] = filter.Role
 	}
 
-	return users, nil
+	page, err := list[models.User](ctx, r.collection, userListing, match, nil, query)
+	if err != nil {
+		return models.Page[models.User]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
 }
 
 func (r *UserRepository) GetUserById(ctx context.Context, userId string) (models.User, error) {
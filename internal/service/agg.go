@@ -8,14 +8,16 @@ import (
 type ReportRepository interface {
 	GetPopularItems(ctx context.Context) ([]models.PopularItem, error)
 	GetTotalSales(ctx context.Context) (float64, error)
+	GetSalesByItem(ctx context.Context) ([]models.ItemSales, error)
 }
 
 type ReportService struct {
 	repo ReportRepository
+	menu *MenuService
 }
 
-func NewReportService(repo ReportRepository) *ReportService {
-	return &ReportService{repo}
+func NewReportService(repo ReportRepository, menu *MenuService) *ReportService {
+	return &ReportService{repo, menu}
 }
 
 func (s *ReportService) GetTotalSales(ctx context.Context) (float64, error) {
@@ -25,3 +27,11 @@ func (s *ReportService) GetTotalSales(ctx context.Context) (float64, error) {
 func (s *ReportService) GetPopularItems(ctx context.Context) ([]models.PopularItem, error) {
 	return s.repo.GetPopularItems(ctx)
 }
+
+func (s *ReportService) GetSalesByItem(ctx context.Context) ([]models.ItemSales, error) {
+	return s.repo.GetSalesByItem(ctx)
+}
+
+func (s *ReportService) GetMargins(ctx context.Context) ([]models.MenuItemCost, error) {
+	return s.menu.GetMargins(ctx)
+}
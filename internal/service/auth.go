@@ -76,7 +76,7 @@ func (s *AuthService) RegisterUser(ctx context.Context, payload models.RegisterU
 		Username: payload.Username,
 		Email:    payload.Email,
 		Password: hashedPassword,
-		Role:     "client",
+		Role:     models.RoleClient,
 	}
 
 	// call create register method to register new user
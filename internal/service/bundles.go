@@ -0,0 +1,104 @@
+package service
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// resolveComponents picks the menu item of every slot of the bundle, checks the picks the customer made
+// for the slots that offer a choice, and splits price across the components in proportion to what they
+// cost on their own. It returns nil for menu items that aren't bundles.
+func (s *OrderService) resolveComponents(ctx context.Context, bundle models.MenuItem, picks []models.OrderItemComponent, price float64) ([]models.OrderItemComponent, error) {
+	if len(bundle.Components) == 0 {
+		if len(picks) > 0 {
+			return nil, fmt.Errorf("%w: %s is not a bundle", ErrInvalidOrder, bundle.ProductId)
+		}
+		return nil, nil
+	}
+	for _, pick := range picks {
+		if !slices.ContainsFunc(bundle.Components, func(slot models.BundleSlot) bool { return slot.SlotID == pick.SlotID }) {
+			return nil, fmt.Errorf("%w: %s has no slot %q", ErrInvalidOrder, bundle.ProductId, pick.SlotID)
+		}
+	}
+
+	components := make([]models.OrderItemComponent, len(bundle.Components))
+	standalone := make([]float64, len(bundle.Components))
+	var total float64
+	for i, slot := range bundle.Components {
+		var pick models.OrderItemComponent
+		if j := slices.IndexFunc(picks, func(pick models.OrderItemComponent) bool { return pick.SlotID == slot.SlotID }); j >= 0 {
+			pick = picks[j]
+		}
+		if pick.ProductID == "" && len(slot.ProductIDs) == 1 {
+			pick.ProductID = slot.ProductIDs[0]
+		}
+		if pick.ProductID == "" {
+			return nil, fmt.Errorf("%w: %s needs a choice for %q", ErrInvalidOrder, bundle.ProductId, slot.Name)
+		}
+		if !slices.Contains(slot.ProductIDs, pick.ProductID) {
+			return nil, fmt.Errorf("%w: %s can't be picked for %q", ErrInvalidOrder, pick.ProductID, slot.Name)
+		}
+		if slot.VariantID != "" {
+			if pick.VariantID != "" && pick.VariantID != slot.VariantID {
+				return nil, fmt.Errorf("%w: %q only comes as %q", ErrInvalidOrder, slot.Name, slot.VariantID)
+			}
+			pick.VariantID = slot.VariantID
+		}
+
+		menuItem, err := s.MenuService.GetMenuItemById(ctx, pick.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %w", pick.ProductID, err)
+		}
+		if menuItem.ArchivedAt != "" {
+			return nil, fmt.Errorf("%w: %s is no longer on the menu", ErrInvalidOrder, pick.ProductID)
+		}
+		if !menuItem.OnSale {
+			return nil, fmt.Errorf("%w: %s is not on sale right now", ErrInvalidOrder, pick.ProductID)
+		}
+		variant, err := resolveVariant(menuItem, pick.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		components[i] = models.OrderItemComponent{
+			SlotID:    slot.SlotID,
+			ProductID: pick.ProductID,
+			VariantID: pick.VariantID,
+			Quantity:  slot.Quantity,
+			Name:      variant.Name,
+			Station:   menuItem.Station,
+		}
+		standalone[i] = variant.Price * float64(slot.Quantity)
+		total += standalone[i]
+	}
+
+	for i := range components {
+		if total > 0 {
+			components[i].AllocatedPrice = price * standalone[i] / total
+		} else {
+			components[i].AllocatedPrice = price / float64(len(components))
+		}
+	}
+	return components, nil
+}
+
+// componentRecipes returns the ingredients of one bundle: the recipes of its components, each times its quantity.
+func (s *OrderService) componentRecipes(ctx context.Context, components []models.OrderItemComponent) ([]models.MenuItemIngredient, error) {
+	var recipe []models.MenuItemIngredient
+	for _, component := range components {
+		menuItem, err := s.MenuService.GetMenuItemById(ctx, component.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %w", component.ProductID, err)
+		}
+		variant, err := resolveVariant(menuItem, component.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ingredient := range variant.Ingredients {
+			ingredient.Quantity *= float64(component.Quantity)
+			recipe = append(recipe, ingredient)
+		}
+	}
+	return recipe, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+)
+
+type CategoryRepository interface {
+	CreateCategory(ctx context.Context, category models.Category) (string, error)
+	GetAllCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoryById(ctx context.Context, id string) (models.Category, error)
+	UpdateCategoryById(ctx context.Context, id string, category models.Category) error
+	DeleteCategoryById(ctx context.Context, id string) error
+}
+
+func (s *MenuService) CreateCategory(ctx context.Context, category models.Category) (string, error) {
+	const op = "service.CreateCategory"
+	id, err := s.Categories.CreateCategory(ctx, category)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+func (s *MenuService) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	const op = "service.GetAllCategories"
+	categories, err := s.Categories.GetAllCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return categories, nil
+}
+
+func (s *MenuService) GetCategoryById(ctx context.Context, id string) (models.Category, error) {
+	const op = "service.GetCategoryById"
+	category, err := s.Categories.GetCategoryById(ctx, id)
+	if err != nil {
+		return models.Category{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return category, nil
+}
+
+func (s *MenuService) UpdateCategoryById(ctx context.Context, id string, category models.Category) error {
+	const op = "service.UpdateCategoryById"
+	category.CategoryID = id
+	if err := s.Categories.UpdateCategoryById(ctx, id, category); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteCategoryById deletes a category that no menu item belongs to anymore.
+func (s *MenuService) DeleteCategoryById(ctx context.Context, id string) error {
+	const op = "service.DeleteCategoryById"
+	count, err := s.Repo.CountMenuItemsInCategory(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%s: %w: %d menu items are in category %s", op, ErrInUse, count, id)
+	}
+	if err := s.Categories.DeleteCategoryById(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
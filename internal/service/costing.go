@@ -0,0 +1,157 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/units"
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// GetMenuItemCost returns the ingredient cost and margin of the menu item, one entry per variant.
+func (s *MenuService) GetMenuItemCost(ctx context.Context, id string) ([]models.MenuItemCost, error) {
+	const op = "service.GetMenuItemCost"
+
+	item, err := s.Repo.GetMenuItemById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	costs, err := s.costs(ctx, []models.MenuItem{item})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return costs, nil
+}
+
+// GetMargins returns the cost and margin of every menu item and variant, least profitable first.
+func (s *MenuService) GetMargins(ctx context.Context) ([]models.MenuItemCost, error) {
+	const op = "service.GetMargins"
+
+	items, err := s.Repo.GetAllMenuItems(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	costs, err := s.costs(ctx, items)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	slices.SortStableFunc(costs, func(a, b models.MenuItemCost) int {
+		switch {
+		case a.MarginPercent < b.MarginPercent:
+			return -1
+		case a.MarginPercent > b.MarginPercent:
+			return 1
+		}
+		return 0
+	})
+	return costs, nil
+}
+
+// costs works out the cost of the items from the current unit costs of their ingredients, which are
+// fetched in one query. The slots of bundles are costed with their most expensive product.
+func (s *MenuService) costs(ctx context.Context, items []models.MenuItem) ([]models.MenuItemCost, error) {
+	components, err := s.bundleComponents(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	stocked, err := s.InventoryService.GetInventoryItemsByIngredients(ctx, menuIngredients(append(slices.Clone(items), components...)))
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]models.MenuItem, len(components))
+	for _, component := range components {
+		byId[component.ProductId] = component
+	}
+
+	costs := []models.MenuItemCost{}
+	for _, item := range items {
+		switch {
+		case len(item.Components) > 0:
+			cost := models.MenuItemCost{ProductId: item.ProductId, Name: item.Name, Price: item.Price, Ingredients: []models.IngredientCost{}}
+			for _, slot := range item.Components {
+				var best models.MenuItemCost
+				for _, productID := range slot.ProductIDs {
+					component, ok := byId[productID]
+					if !ok {
+						continue
+					}
+					if option := mostExpensive(component, slot.VariantID, stocked); option.IngredientCost >= best.IngredientCost {
+						best = option
+					}
+				}
+				for _, ingredient := range best.Ingredients {
+					ingredient.Quantity *= float64(slot.Quantity)
+					ingredient.Cost *= float64(slot.Quantity)
+					cost.Ingredients = append(cost.Ingredients, ingredient)
+				}
+				cost.MissingCosts = append(cost.MissingCosts, best.MissingCosts...)
+			}
+			costs = append(costs, withMargin(cost))
+		case len(item.Variants) > 0:
+			for _, variant := range item.Variants {
+				costs = append(costs, recipeCost(item.ProductId, variant.VariantID, item.Name+" ("+variant.Name+")", variant.Price, variant.Ingredients, stocked))
+			}
+		default:
+			costs = append(costs, recipeCost(item.ProductId, "", item.Name, item.Price, item.Ingredients, stocked))
+		}
+	}
+	return costs, nil
+}
+
+// mostExpensive returns the cost of the component in the given variant, or of its most expensive variant when none is given.
+func mostExpensive(component models.MenuItem, variantID string, stocked map[string]models.InventoryItem) models.MenuItemCost {
+	if len(component.Variants) == 0 {
+		return recipeCost(component.ProductId, "", component.Name, component.Price, component.Ingredients, stocked)
+	}
+	var best models.MenuItemCost
+	for _, variant := range component.Variants {
+		if variantID != "" && variant.VariantID != variantID {
+			continue
+		}
+		cost := recipeCost(component.ProductId, variant.VariantID, component.Name, variant.Price, variant.Ingredients, stocked)
+		if cost.IngredientCost >= best.IngredientCost {
+			best = cost
+		}
+	}
+	return best
+}
+
+func recipeCost(productID, variantID, name string, price float64, recipe []models.MenuItemIngredient, stocked map[string]models.InventoryItem) models.MenuItemCost {
+	cost := models.MenuItemCost{ProductId: productID, VariantID: variantID, Name: name, Price: price, Ingredients: []models.IngredientCost{}}
+	for _, ingredient := range recipe {
+		item, ok := stocked[ingredient.IngredientID]
+		if !ok || item.UnitCost <= 0 {
+			cost.MissingCosts = append(cost.MissingCosts, ingredient.IngredientID)
+			continue
+		}
+		qty := ingredient.Quantity
+		if ingredient.Unit != "" {
+			var err error
+			if qty, err = units.Convert(ingredient.Quantity, ingredient.Unit, item.Unit); err != nil {
+				cost.MissingCosts = append(cost.MissingCosts, ingredient.IngredientID)
+				continue
+			}
+		}
+		cost.Ingredients = append(cost.Ingredients, models.IngredientCost{
+			IngredientID: ingredient.IngredientID,
+			Quantity:     qty,
+			Unit:         item.Unit,
+			UnitCost:     item.UnitCost,
+			Cost:         qty * item.UnitCost,
+		})
+	}
+	return withMargin(cost)
+}
+
+// withMargin totals the ingredient costs and works out the margin they leave.
+func withMargin(cost models.MenuItemCost) models.MenuItemCost {
+	cost.IngredientCost = 0
+	for _, ingredient := range cost.Ingredients {
+		cost.IngredientCost += ingredient.Cost
+	}
+	cost.GrossMargin = cost.Price - cost.IngredientCost
+	if cost.Price > 0 {
+		cost.MarginPercent = cost.GrossMargin / cost.Price * 100
+	}
+	return cost
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/units"
+	"cofee-shop-mongo/models"
+	"slices"
+	"strings"
+)
+
+// setDietaryInfo works out the allergens and nutrition facts of one serving of the item and its variants
+// from the stocked ingredients, and the allergens every modifier brings in. An item with variants has the
+// allergens of all of them, while its nutrition facts are only given per variant.
+func setDietaryInfo(item *models.MenuItem, stocked map[string]models.InventoryItem) {
+	if len(item.Variants) == 0 {
+		item.Allergens = recipeAllergens(item.Ingredients, stocked)
+		nutrition := recipeNutrition(item.Ingredients, stocked)
+		item.Nutrition = &nutrition
+	} else {
+		item.Allergens = []string{}
+		for i := range item.Variants {
+			variant := &item.Variants[i]
+			variant.Allergens = recipeAllergens(variant.Ingredients, stocked)
+			nutrition := recipeNutrition(variant.Ingredients, stocked)
+			variant.Nutrition = &nutrition
+			item.Allergens = mergeAllergens(item.Allergens, variant.Allergens)
+		}
+	}
+
+	for i := range item.ModifierGroups {
+		for j := range item.ModifierGroups[i].Modifiers {
+			modifier := &item.ModifierGroups[i].Modifiers[j]
+			modifier.Allergens = nil
+			for _, change := range modifier.Ingredients {
+				if stockedItem, ok := stocked[change.IngredientID]; ok {
+					modifier.Allergens = mergeAllergens(modifier.Allergens, stockedItem.Allergens)
+				}
+			}
+		}
+	}
+}
+
+// bundleAllergens returns the allergens of every product that can be picked for the bundle.
+func bundleAllergens(bundle models.MenuItem, components map[string]models.MenuItem) []string {
+	allergens := []string{}
+	for _, slot := range bundle.Components {
+		for _, productID := range slot.ProductIDs {
+			allergens = mergeAllergens(allergens, components[productID].Allergens)
+		}
+	}
+	return allergens
+}
+
+func recipeAllergens(recipe []models.MenuItemIngredient, stocked map[string]models.InventoryItem) []string {
+	allergens := []string{}
+	for _, ingredient := range recipe {
+		allergens = mergeAllergens(allergens, stocked[ingredient.IngredientID].Allergens)
+	}
+	return allergens
+}
+
+func recipeNutrition(recipe []models.MenuItemIngredient, stocked map[string]models.InventoryItem) models.Nutrition {
+	var nutrition models.Nutrition
+	for _, ingredient := range recipe {
+		item, ok := stocked[ingredient.IngredientID]
+		if !ok {
+			continue
+		}
+		qty := ingredient.Quantity
+		if ingredient.Unit != "" {
+			var err error
+			if qty, err = units.Convert(ingredient.Quantity, ingredient.Unit, item.Unit); err != nil {
+				continue
+			}
+		}
+		nutrition.Kcal += qty * item.Nutrition.Kcal
+		nutrition.Sugar += qty * item.Nutrition.Sugar
+		nutrition.Caffeine += qty * item.Nutrition.Caffeine
+	}
+	return nutrition
+}
+
+// mergeAllergens adds the allergens of add that aren't in allergens yet, keeping them sorted.
+func mergeAllergens(allergens, add []string) []string {
+	for _, allergen := range add {
+		if !slices.Contains(allergens, allergen) {
+			allergens = append(allergens, allergen)
+		}
+	}
+	slices.Sort(allergens)
+	return allergens
+}
+
+// containsAnyAllergen reports whether any of the excluded allergens is among allergens.
+func containsAnyAllergen(allergens, excluded []string) bool {
+	return slices.ContainsFunc(excluded, func(allergen string) bool { return slices.Contains(allergens, allergen) })
+}
+
+// normalizeAllergens lower-cases the allergen tags and drops blanks and duplicates, so "Dairy " matches "dairy".
+func normalizeAllergens(allergens []string) []string {
+	normalized := []string{}
+	for _, allergen := range allergens {
+		if allergen = strings.ToLower(strings.TrimSpace(allergen)); allergen != "" {
+			normalized = mergeAllergens(normalized, []string{allergen})
+		}
+	}
+	return normalized
+}
@@ -1,9 +1,49 @@
 package service
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	ErrNotEnoughStock       = errors.New("not enough stock for")
 	ErrAlreadyExists        = errors.New("already exists")
 	ErrInvalidPasswordEmail = errors.New("invalid password or email")
+	ErrUnknownStatus        = errors.New("unknown order status")
+	ErrIllegalTransition    = errors.New("illegal order status transition")
+	ErrTransitionForbidden  = errors.New("not allowed to perform this status transition")
+	ErrOrderNotEditable     = errors.New("order can only be edited while pending")
+	ErrInvalidRefund        = errors.New("invalid refund")
+	ErrInvalidOrder         = errors.New("invalid order")
+	ErrInvalidPurchaseOrder = errors.New("invalid purchase order")
+	ErrInvalidInventoryItem = errors.New("invalid inventory item")
+	ErrInvalidMenuItem      = errors.New("invalid menu item")
+	ErrInUse                = errors.New("still in use")
+	ErrInvalidPriceChange   = errors.New("invalid price change")
+	ErrUnknownTicketStatus  = errors.New("unknown ticket status")
+	ErrCheckInProgress      = errors.New("an integrity check is running already")
 )
+
+// OutOfStockError names the ingredient that ran out while placing an order.
+type OutOfStockError struct {
+	Ingredient string
+}
+
+func (e *OutOfStockError) Error() string {
+	return "out of stock: " + e.Ingredient
+}
+
+// InUseError lists the menu items whose recipes still use an ingredient that was about to be deleted.
+type InUseError struct {
+	Ingredient string
+	MenuItems  []string
+}
+
+func (e *InUseError) Error() string {
+	return fmt.Sprintf("%s is still used by %s", e.Ingredient, strings.Join(e.MenuItems, ", "))
+}
+
+func (e *InUseError) Unwrap() error {
+	return ErrInUse
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type IntegrityRepository interface {
+	FindDanglingReferences(ctx context.Context, check models.ReferenceCheck) ([]models.DanglingReference, error)
+	CreateIntegrityRun(ctx context.Context, run models.IntegrityRun) error
+	UpdateIntegrityRun(ctx context.Context, run models.IntegrityRun) error
+	GetLatestIntegrityRun(ctx context.Context) (models.IntegrityRun, error)
+	FailStaleIntegrityRuns(ctx context.Context, startedBefore, now string) error
+	DeleteIntegrityRunsExcept(ctx context.Context, runID string) error
+	AddDanglingReferences(ctx context.Context, references []models.DanglingReference) error
+	ListDanglingReferences(ctx context.Context, runID string, query models.ListQuery) (models.Page[models.DanglingReference], error)
+}
+
+// referenceChecks are the references between the collections, writes check them but documents
+// written before that, or changed by hand, can still point nowhere.
+var referenceChecks = []models.ReferenceCheck{
+	{Collection: "menu", IDField: "product_id", Field: "ingredients.ingredient_id", Target: "inventory", TargetField: "ingredient_id"},
+	{Collection: "menu", IDField: "product_id", Field: "variants.ingredients.ingredient_id", Target: "inventory", TargetField: "ingredient_id"},
+	{Collection: "menu", IDField: "product_id", Field: "modifier_groups.modifiers.ingredients.ingredient_id", Target: "inventory", TargetField: "ingredient_id"},
+	{Collection: "menu", IDField: "product_id", Field: "modifier_groups.modifiers.ingredients.replaces", Target: "inventory", TargetField: "ingredient_id"},
+	{Collection: "menu", IDField: "product_id", Field: "category_id", Target: "categories", TargetField: "category_id"},
+	{Collection: "menu", IDField: "product_id", Field: "components.product_ids", Target: "menu", TargetField: "product_id"},
+	{Collection: "orders", IDField: "order_id", Field: "items.product_id", Target: "menu", TargetField: "product_id"},
+	{Collection: "orders", IDField: "order_id", Field: "items.components.product_id", Target: "menu", TargetField: "product_id"},
+	{Collection: "kitchen_tickets", IDField: "ticket_id", Field: "order_id", Target: "orders", TargetField: "order_id"},
+	{Collection: "price_changes", IDField: "change_id", Field: "product_id", Target: "menu", TargetField: "product_id"},
+	{Collection: "inventory_movements", IDField: "ingredient_id", Field: "ingredient_id", Target: "inventory", TargetField: "ingredient_id"},
+	{Collection: "suppliers", IDField: "supplier_id", Field: "products.ingredient_id", Target: "inventory", TargetField: "ingredient_id"},
+	{Collection: "purchase_orders", IDField: "purchase_order_id", Field: "supplier_id", Target: "suppliers", TargetField: "supplier_id"},
+	{Collection: "purchase_orders", IDField: "purchase_order_id", Field: "lines.ingredient_id", Target: "inventory", TargetField: "ingredient_id"},
+}
+
+type IntegrityService struct {
+	Repo IntegrityRepository
+	// Timeout bounds a whole integrity check, a check still running after it counts as failed.
+	Timeout time.Duration
+}
+
+func NewIntegrityService(repo IntegrityRepository, timeout time.Duration) *IntegrityService {
+	return &IntegrityService{Repo: repo, Timeout: timeout}
+}
+
+// StartIntegrityCheck starts scanning the collections for references to documents that don't exist, in the
+// background, and returns the check. Only one check runs at a time, ErrCheckInProgress is returned
+// while one is. Archived documents still exist, so only references to documents that were removed from the
+// database count.
+func (s *IntegrityService) StartIntegrityCheck(ctx context.Context) (models.IntegrityRun, error) {
+	const op = "service.StartIntegrityCheck"
+
+	now := time.Now().UTC()
+	if err := s.Repo.FailStaleIntegrityRuns(ctx, now.Add(-s.Timeout).Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		return models.IntegrityRun{}, fmt.Errorf("%s: %w", op, err)
+	}
+	run := models.IntegrityRun{
+		RunID:     "IC-" + utils.GenerateRandomString(8),
+		Status:    models.IntegrityCheckRunning,
+		StartedAt: now.Format(time.RFC3339),
+		Checks:    len(referenceChecks),
+	}
+	if err := s.Repo.CreateIntegrityRun(ctx, run); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return models.IntegrityRun{}, fmt.Errorf("%s: %w", op, ErrCheckInProgress)
+		}
+		return models.IntegrityRun{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	go s.runIntegrityCheck(run)
+	return run, nil
+}
+
+// runIntegrityCheck runs the checks one by one, storing the references each finds, and records how the run
+// ended. Once it is done the earlier runs are removed.
+func (s *IntegrityService) runIntegrityCheck(run models.IntegrityRun) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	run.Status = models.IntegrityCheckDone
+	for _, check := range referenceChecks {
+		if err := s.check(ctx, &run, check); err != nil {
+			run.Status = models.IntegrityCheckFailed
+			run.Error = fmt.Sprintf("%s.%s: %v", check.Collection, check.Field, err)
+			break
+		}
+	}
+	run.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+
+	// the run may have used up its context
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Repo.UpdateIntegrityRun(ctx, run); err != nil {
+		return
+	}
+	if run.Status == models.IntegrityCheckDone {
+		s.Repo.DeleteIntegrityRunsExcept(ctx, run.RunID)
+	}
+}
+
+func (s *IntegrityService) check(ctx context.Context, run *models.IntegrityRun, check models.ReferenceCheck) error {
+	dangling, err := s.Repo.FindDanglingReferences(ctx, check)
+	if err != nil {
+		return err
+	}
+	for i := range dangling {
+		dangling[i].RunID = run.RunID
+		dangling[i].Seq = run.DanglingCount + i
+	}
+	if err := s.Repo.AddDanglingReferences(ctx, dangling); err != nil {
+		return err
+	}
+	run.DanglingCount += len(dangling)
+	return s.Repo.UpdateIntegrityRun(ctx, *run)
+}
+
+// GetIntegrityReport returns the integrity check started last with one page of the references it found,
+// which are all the references found so far while it is running.
+func (s *IntegrityService) GetIntegrityReport(ctx context.Context, query models.ListQuery) (models.IntegrityReport, error) {
+	const op = "service.GetIntegrityReport"
+
+	run, err := s.Repo.GetLatestIntegrityRun(ctx)
+	if err != nil {
+		return models.IntegrityReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	references, err := s.Repo.ListDanglingReferences(ctx, run.RunID, query)
+	if err != nil {
+		return models.IntegrityReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return models.IntegrityReport{IntegrityRun: run, DanglingReferences: references}, nil
+}
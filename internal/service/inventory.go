@@ -1,32 +1,71 @@
 package service
 
 import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/units"
 	"cofee-shop-mongo/models"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 )
 
 type InventoryRepository interface {
-	GetAllInventoryItems(ctx context.Context) ([]models.InventoryItem, error)
+	ListInventoryItems(ctx context.Context, filter models.InventoryFilter, query models.ListQuery) (models.Page[models.InventoryItem], error)
 	GetInventoryItemById(ctx context.Context, id string) (models.InventoryItem, error)
-	DeleteInventoryItemById(ctx context.Context, id string) error
+	ArchiveInventoryItemById(ctx context.Context, id, archivedAt string) error
+	RestoreInventoryItemById(ctx context.Context, id string) error
 	UpdateInventoryItemById(ctx context.Context, id string, item models.InventoryItem) error
 	CreateInventoryItem(ctx context.Context, item models.InventoryItem) (string, error)
+	DeductInventoryItemQuantity(ctx context.Context, id string, qty float64) error
+	IncrementInventoryItemQuantity(ctx context.Context, id string, qty float64) error
+	RestockInventoryItem(ctx context.Context, id string, qty, unitCost float64) error
+	ReserveInventoryItemQuantity(ctx context.Context, id string, qty float64) error
+	ReleaseInventoryItemReservation(ctx context.Context, id string, qty float64) error
+	CommitInventoryItemReservation(ctx context.Context, id string, qty float64) error
+	GetLowStockItems(ctx context.Context) ([]models.InventoryItem, error)
+	GetInventoryItemsByIds(ctx context.Context, ids []string) ([]models.InventoryItem, error)
+}
+
+type MovementRepository interface {
+	CreateMovement(ctx context.Context, movement models.InventoryMovement) error
+	GetMovementsByIngredient(ctx context.Context, ingredientID, from, to string) ([]models.InventoryMovement, error)
+}
+
+// RecipeRepository finds the menu items whose recipes use an ingredient.
+type RecipeRepository interface {
+	GetMenuItemsByIngredient(ctx context.Context, ingredientID string) ([]models.MenuItem, error)
+}
+
+// SupplierCatalog finds the suppliers that sell an ingredient.
+type SupplierCatalog interface {
+	GetSuppliersByIngredient(ctx context.Context, ingredientID string) ([]models.Supplier, error)
+}
+
+type LowStockNotifier interface {
+	NotifyLowStock(ctx context.Context, alert models.LowStockAlert) error
 }
 
 type InventoryService struct {
-	Repo InventoryRepository
+	Repo      InventoryRepository
+	Movements MovementRepository
+	Recipes   RecipeRepository
+	Suppliers SupplierCatalog
+	Tx        Transactor
+	Notifier  LowStockNotifier
 }
 
-func NewInventoryService(repo InventoryRepository) *InventoryService {
-	return &InventoryService{Repo: repo}
+func NewInventoryService(repo InventoryRepository, movements MovementRepository, recipes RecipeRepository, suppliers SupplierCatalog, tx Transactor, notifier LowStockNotifier) *InventoryService {
+	return &InventoryService{Repo: repo, Movements: movements, Recipes: recipes, Suppliers: suppliers, Tx: tx, Notifier: notifier}
 }
 
-func (s *InventoryService) GetAllInventoryItems(ctx context.Context) ([]models.InventoryItem, error) {
+func (s *InventoryService) GetAllInventoryItems(ctx context.Context, filter models.InventoryFilter, query models.ListQuery) (models.Page[models.InventoryItem], error) {
 	const op = "service.GetAllInventoryItems"
-	items, err := s.Repo.GetAllInventoryItems(ctx)
+	items, err := s.Repo.ListInventoryItems(ctx, filter, query)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.Page[models.InventoryItem]{}, fmt.Errorf("%s: %w", op, err)
 	}
 	return items, nil
 }
@@ -40,58 +79,358 @@ func (s *InventoryService) GetInventoryItemById(ctx context.Context, InventoryId
 	return item, nil
 }
 
+// DeleteInventoryItemById archives the item. Items the recipe of a menu item that isn't archived still
+// uses can't be deleted, the returned InUseError names those menu items.
 func (s *InventoryService) DeleteInventoryItemById(ctx context.Context, InventoryId string) error {
 	const op = "service.DeleteInventoryItemById"
-	err := s.Repo.DeleteInventoryItemById(ctx, InventoryId)
+	err := s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		items, err := s.Recipes.GetMenuItemsByIngredient(ctx, InventoryId)
+		if err != nil {
+			return err
+		}
+		if len(items) > 0 {
+			inUse := &InUseError{Ingredient: InventoryId}
+			for _, item := range items {
+				inUse.MenuItems = append(inUse.MenuItems, item.ProductId)
+			}
+			return inUse
+		}
+		return s.Repo.ArchiveInventoryItemById(ctx, InventoryId, time.Now().UTC().Format(time.RFC3339))
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RestoreInventoryItemById brings an archived item back, so that recipes can use it again.
+func (s *InventoryService) RestoreInventoryItemById(ctx context.Context, InventoryId string) error {
+	const op = "service.RestoreInventoryItemById"
+	err := s.Repo.RestoreInventoryItemById(ctx, InventoryId)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-func (s *InventoryService) UpdateInventoryItemById(ctx context.Context, InventoryId string, item models.InventoryItem) error {
+// UpdateInventoryItemById overwrites the item and records the change of its quantity in the
+// ledger, using the reason and note of movement. The unit cost is kept when none is given, and for
+// a restock it is the price of the added stock, which is averaged into the unit cost.
+func (s *InventoryService) UpdateInventoryItemById(ctx context.Context, InventoryId string, item models.InventoryItem, movement models.InventoryMovement) error {
 	const op = "service.UpdateInventoryItemById"
 	item.IngredientID = InventoryId
-	err := s.Repo.UpdateInventoryItemById(ctx, InventoryId, item)
+	unit, err := units.Normalize(item.Unit)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", op, ErrInvalidInventoryItem, err)
+	}
+	item.Unit = unit
+	item.Allergens = normalizeAllergens(item.Allergens)
+	err = s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.Repo.GetInventoryItemById(ctx, InventoryId)
+		if err != nil {
+			return err
+		}
+		if item.Unit != current.Unit {
+			if err := s.checkUnitChange(ctx, current, item.Unit); err != nil {
+				return err
+			}
+			// the kept unit cost is the price of the old unit
+			perUnit, _ := units.Convert(1, item.Unit, current.Unit)
+			current.UnitCost *= perUnit
+		}
+		// open orders hold their reservation until they are picked up, stock can't be counted below it
+		if item.Quantity < current.Reserved {
+			return fmt.Errorf("%w: quantity %v is below the %v %s reserved for open orders", ErrInvalidInventoryItem, item.Quantity, current.Reserved, current.Unit)
+		}
+		switch {
+		case item.UnitCost == 0:
+			item.UnitCost = current.UnitCost
+		case movement.Reason == models.MovementRestock && item.Quantity > current.Quantity:
+			// the cost given with a restock is what the new stock was bought at
+			movement.UnitCost = item.UnitCost
+			item.UnitCost = weightedUnitCost(current.Quantity, current.UnitCost, item.Quantity-current.Quantity, item.UnitCost)
+		}
+		if err := s.Repo.UpdateInventoryItemById(ctx, InventoryId, item); err != nil {
+			return err
+		}
+		if item.Quantity == current.Quantity {
+			return nil
+		}
+		_, err = s.recordMovement(ctx, InventoryId, item.Quantity-current.Quantity, movement)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
+// checkUnitChange reports whether the item may be stocked in unit from now on. Recipes are written against the
+// stocked unit, so it may only change within its dimension, and only while nothing is counted in the old unit: no
+// stock, no reservations and no supplier packs.
+func (s *InventoryService) checkUnitChange(ctx context.Context, current models.InventoryItem, unit string) error {
+	if _, err := units.Convert(0, current.Unit, unit); errors.Is(err, units.ErrIncompatibleUnits) {
+		return fmt.Errorf("%w: unit can't change from %s to %s", ErrInvalidInventoryItem, current.Unit, unit)
+	}
+	if current.Quantity != 0 || current.Reserved != 0 {
+		return fmt.Errorf("%w: unit can't change while %s is in stock or reserved", ErrInvalidInventoryItem, current.IngredientID)
+	}
+	suppliers, err := s.Suppliers.GetSuppliersByIngredient(ctx, current.IngredientID)
+	if err != nil {
+		return err
+	}
+	if len(suppliers) > 0 {
+		return fmt.Errorf("%w: unit can't change while suppliers sell %s in packs of it", ErrInvalidInventoryItem, current.IngredientID)
+	}
+	return nil
+}
+
 func (s *InventoryService) CreateInventoryItem(ctx context.Context, item models.InventoryItem) (string, error) {
 	const op = "service.CreateInventoryItem"
-	id, err := s.Repo.CreateInventoryItem(ctx, item)
+	item.Reserved = 0
+	item.ArchivedAt = ""
+	unit, err := units.Normalize(item.Unit)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %w", op, ErrInvalidInventoryItem, err)
+	}
+	item.Unit = unit
+	item.Allergens = normalizeAllergens(item.Allergens)
+	var id string
+	err = s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = s.Repo.CreateInventoryItem(ctx, item)
+		if err != nil {
+			return err
+		}
+		_, err = s.recordMovement(ctx, id, item.Quantity, models.InventoryMovement{Reason: models.MovementInitialStock})
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 	return id, nil
 }
 
-func (s *InventoryService) DeductStock(ctx context.Context, ingredientID string, qty float64) error {
-	const op = "service.DeductStock"
+// InStockUnits converts the quantity of every recipe ingredient into the unit the ingredient is stocked in.
+// Ingredients without a unit are rejected with units.ErrUnknownUnit.
+func (s *InventoryService) InStockUnits(ctx context.Context, ingredients []models.MenuItemIngredient) ([]models.MenuItemIngredient, error) {
+	const op = "service.InStockUnits"
+	stocked, err := s.GetInventoryItemsByIngredients(ctx, ingredients)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	converted := make([]models.MenuItemIngredient, len(ingredients))
+	for i, ingredient := range ingredients {
+		item, ok := stocked[ingredient.IngredientID]
+		if !ok {
+			return nil, fmt.Errorf("%s: %s, %w", op, ingredient.IngredientID, repository.ErrNotFound)
+		}
+		if ingredient.Unit == "" {
+			return nil, fmt.Errorf("%s: %s, %w: no unit given", op, ingredient.IngredientID, units.ErrUnknownUnit)
+		}
+		ingredient.Quantity, err = units.Convert(ingredient.Quantity, ingredient.Unit, item.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s, %w", op, ingredient.IngredientID, err)
+		}
+		ingredient.Unit = item.Unit
+		converted[i] = ingredient
+	}
+	return converted, nil
+}
+
+// GetInventoryItemsByIngredients looks up the inventory items of all the ingredients at once, keyed by ingredient id.
+// Ingredients that aren't stocked are missing from the result.
+func (s *InventoryService) GetInventoryItemsByIngredients(ctx context.Context, ingredients []models.MenuItemIngredient) (map[string]models.InventoryItem, error) {
+	const op = "service.GetInventoryItemsByIngredients"
+	ids := make([]string, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		if !slices.Contains(ids, ingredient.IngredientID) {
+			ids = append(ids, ingredient.IngredientID)
+		}
+	}
+	items, err := s.Repo.GetInventoryItemsByIds(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	stocked := make(map[string]models.InventoryItem, len(items))
+	for _, item := range items {
+		stocked[item.IngredientID] = item
+	}
+	return stocked, nil
+}
+
+// GetInventoryMovements returns the ledger of the item between the optional UTC RFC3339 bounds from and to.
+func (s *InventoryService) GetInventoryMovements(ctx context.Context, InventoryId, from, to string) ([]models.InventoryMovement, error) {
+	const op = "service.GetInventoryMovements"
+	if _, err := s.Repo.GetInventoryItemById(ctx, InventoryId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	movements, err := s.Movements.GetMovementsByIngredient(ctx, InventoryId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return movements, nil
+}
+
+// GetLowStockItems returns the items whose quantity dropped to their reorder point.
+func (s *InventoryService) GetLowStockItems(ctx context.Context) ([]models.InventoryItem, error) {
+	const op = "service.GetLowStockItems"
+	items, err := s.Repo.GetLowStockItems(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nil
+}
 
+// recordMovement appends a change of delta to the item's ledger, together with the balance it led to,
+// and returns the item as it is after the change. It must run in the same transaction as the change
+// itself, so the balance it reads is the one the change produced.
+func (s *InventoryService) recordMovement(ctx context.Context, ingredientID string, delta float64, movement models.InventoryMovement) (models.InventoryItem, error) {
 	item, err := s.Repo.GetInventoryItemById(ctx, ingredientID)
 	if err != nil {
+		return models.InventoryItem{}, err
+	}
+	movement.IngredientID = ingredientID
+	movement.Delta = delta
+	movement.Balance = item.Quantity
+	if movement.UserID == "" {
+		movement.UserID = auth.UserIDFromContext(ctx)
+	}
+	movement.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := s.Movements.CreateMovement(ctx, movement); err != nil {
+		return models.InventoryItem{}, err
+	}
+	return item, nil
+}
+
+// alertIfLow notifies about the item once the deduction of qty brought it down to its reorder point.
+// The alert goes out after the transaction commits, in the background, so a slow webhook doesn't hold up orders.
+func (s *InventoryService) alertIfLow(ctx context.Context, item models.InventoryItem, qty float64) {
+	if s.Notifier == nil || item.ReorderPoint <= 0 {
+		return
+	}
+	if item.Quantity > item.ReorderPoint || item.Quantity+qty <= item.ReorderPoint {
+		return
+	}
+
+	alert := models.LowStockAlert{
+		IngredientID:    item.IngredientID,
+		Name:            item.Name,
+		Quantity:        item.Quantity,
+		Unit:            item.Unit,
+		ReorderPoint:    item.ReorderPoint,
+		ReorderQuantity: item.ReorderQuantity,
+		CreatedAt:       time.Now().Format(time.RFC3339),
+	}
+	repository.AfterCommit(ctx, func() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			s.Notifier.NotifyLowStock(ctx, alert)
+		}()
+	})
+}
+
+// DeductStock removes qty of the ingredient in a single conditional update, so stock
+// can never go negative even when several orders are closed at the same time.
+// Like the other methods that change the quantity on hand, it writes the change to the ledger
+// with the reason and order of movement and must be called inside a transaction.
+func (s *InventoryService) DeductStock(ctx context.Context, ingredientID string, qty float64, movement models.InventoryMovement) error {
+	const op = "service.DeductStock"
+
+	err := s.Repo.DeductInventoryItemQuantity(ctx, ingredientID, qty)
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientQuantity) {
+			return fmt.Errorf("%s: %w %s", op, ErrNotEnoughStock, ingredientID)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	item, err := s.recordMovement(ctx, ingredientID, -qty, movement)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.alertIfLow(ctx, item, qty)
+
+	return nil
+}
+
+// AddStock puts qty of the ingredient into stock, e.g. after an order was refunded or a delivery arrived.
+// When movement has a unit cost the stock was bought at, the item's unit cost becomes the weighted average.
+func (s *InventoryService) AddStock(ctx context.Context, ingredientID string, qty float64, movement models.InventoryMovement) error {
+	const op = "service.AddStock"
+
+	var err error
+	if movement.UnitCost > 0 {
+		err = s.Repo.RestockInventoryItem(ctx, ingredientID, qty, movement.UnitCost)
+	} else {
+		err = s.Repo.IncrementInventoryItemQuantity(ctx, ingredientID, qty)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := s.recordMovement(ctx, ingredientID, qty, movement); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if item.Quantity < qty {
-		return fmt.Errorf("%s: %w", op, ErrNotEnoughStock)
+	return nil
+}
+
+// ReserveStock holds qty of the ingredient for an order that was not picked up yet.
+func (s *InventoryService) ReserveStock(ctx context.Context, ingredientID string, qty float64) error {
+	const op = "service.ReserveStock"
+
+	err := s.Repo.ReserveInventoryItemQuantity(ctx, ingredientID, qty)
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientQuantity) {
+			name := ingredientID
+			if item, err := s.Repo.GetInventoryItemById(ctx, ingredientID); err == nil {
+				name = item.Name
+			}
+			return fmt.Errorf("%s: %w", op, &OutOfStockError{Ingredient: name})
+		}
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	item.Quantity -= qty
-	if err := s.Repo.UpdateInventoryItemById(ctx, ingredientID, item); err != nil {
+	return nil
+}
+
+// ReleaseStock gives a reservation made by ReserveStock back.
+func (s *InventoryService) ReleaseStock(ctx context.Context, ingredientID string, qty float64) error {
+	const op = "service.ReleaseStock"
+
+	err := s.Repo.ReleaseInventoryItemReservation(ctx, ingredientID, qty)
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (s *InventoryService) HasSufficientStock(ctx context.Context, ingredientID string, requiredQty float64) bool {
-	item, err := s.Repo.GetInventoryItemById(ctx, ingredientID)
+// CommitStock turns a reservation made by ReserveStock into a deduction.
+func (s *InventoryService) CommitStock(ctx context.Context, ingredientID string, qty float64, movement models.InventoryMovement) error {
+	const op = "service.CommitStock"
+
+	err := s.Repo.CommitInventoryItemReservation(ctx, ingredientID, qty)
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientQuantity) {
+			return fmt.Errorf("%s: %w %s", op, ErrNotEnoughStock, ingredientID)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	item, err := s.recordMovement(ctx, ingredientID, -qty, movement)
 	if err != nil {
-		return false
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.alertIfLow(ctx, item, qty)
+
+	return nil
+}
+
+// weightedUnitCost is the unit cost of qty at cost once added is bought at addedCost.
+func weightedUnitCost(qty, cost, added, addedCost float64) float64 {
+	qty = max(qty, 0)
+	if qty+added <= 0 {
+		return addedCost
 	}
-	return item.Quantity >= requiredQty
+	return (qty*cost + added*addedCost) / (qty + added)
 }
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ticketTransitions is the status a bump moves a ticket to.
+var ticketTransitions = map[string]string{
+	models.TicketQueued:     models.TicketInProgress,
+	models.TicketInProgress: models.TicketDone,
+}
+
+// kitchenTickets splits the order into one ticket per station, in the order the stations first appear in
+// it. The components of a bundle go to their own stations, the modifiers picked for the bundle are put on
+// the line of its first component.
+func kitchenTickets(order models.Order, createdAt string) []models.KitchenTicket {
+	var tickets []models.KitchenTicket
+	add := func(station string, line models.TicketItem) {
+		if station == "" {
+			station = models.DefaultStation
+		}
+		i := slices.IndexFunc(tickets, func(ticket models.KitchenTicket) bool { return ticket.Station == station })
+		if i < 0 {
+			tickets = append(tickets, models.KitchenTicket{
+				TicketID:     order.ProductId + "-" + station,
+				OrderID:      order.ProductId,
+				PickupNumber: order.PickupNumber,
+				Station:      station,
+				Status:       models.TicketQueued,
+				CreatedAt:    createdAt,
+			})
+			i = len(tickets) - 1
+		}
+		tickets[i].Items = append(tickets[i].Items, line)
+	}
+
+	for _, item := range order.Items {
+		var modifiers []string
+		for _, modifier := range item.Modifiers {
+			modifiers = append(modifiers, modifier.Name)
+		}
+		if len(item.Components) == 0 {
+			add(item.Station, models.TicketItem{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Name:      item.Name,
+				Quantity:  item.Quantity,
+				Modifiers: modifiers,
+			})
+			continue
+		}
+		for i, component := range item.Components {
+			line := models.TicketItem{
+				ProductID: component.ProductID,
+				VariantID: component.VariantID,
+				Name:      component.Name,
+				Quantity:  item.Quantity * component.Quantity,
+				Bundle:    item.Name,
+			}
+			if i == 0 {
+				line.Modifiers = modifiers
+			}
+			add(component.Station, line)
+		}
+	}
+	return tickets
+}
+
+// GetTickets returns the tickets of the kitchen display, oldest first. Without statuses in the filter the
+// tickets that aren't done are returned.
+func (s *OrderService) GetTickets(ctx context.Context, filter models.TicketFilter) ([]models.KitchenTicket, error) {
+	const op = "service.GetTickets"
+
+	for _, status := range filter.Statuses {
+		if status != models.TicketDone && ticketTransitions[status] == "" {
+			return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownTicketStatus, status)
+		}
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = []string{models.TicketQueued, models.TicketInProgress}
+	}
+
+	tickets, err := s.Tickets.GetTickets(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tickets, nil
+}
+
+// BumpTicket moves the ticket on to its next status: a queued ticket is started and a started one is done.
+// Starting the first ticket of an accepted order puts the order in preparation, and once the last ticket of
+// an order is done the order is ready.
+func (s *OrderService) BumpTicket(ctx context.Context, ticketID string) (models.KitchenTicket, error) {
+	const op = "service.BumpTicket"
+
+	ticket, err := s.Tickets.GetTicketById(ctx, ticketID)
+	if err != nil {
+		return models.KitchenTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+	next, ok := ticketTransitions[ticket.Status]
+	if !ok {
+		return models.KitchenTicket{}, fmt.Errorf("%s: %w: ticket %s is %s", op, ErrIllegalTransition, ticketID, ticket.Status)
+	}
+	now := time.Now().Format(time.RFC3339)
+	if err := s.Tickets.UpdateTicketStatus(ctx, ticketID, ticket.Status, next, now); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return models.KitchenTicket{}, fmt.Errorf("%s: %w: ticket %s was bumped concurrently", op, ErrIllegalTransition, ticketID)
+		}
+		return models.KitchenTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+	ticket.Status = next
+	if next == models.TicketInProgress {
+		ticket.StartedAt = now
+	} else {
+		ticket.DoneAt = now
+	}
+
+	switch next {
+	case models.TicketInProgress:
+		err = s.advanceOrder(ctx, ticket.OrderID, models.OrderStatusInPreparation)
+	case models.TicketDone:
+		var open int64
+		open, err = s.Tickets.CountOpenTickets(ctx, ticket.OrderID)
+		if err == nil && open == 0 {
+			err = s.advanceOrder(ctx, ticket.OrderID, models.OrderStatusInPreparation, models.OrderStatusReady)
+		}
+	}
+	if err != nil {
+		return models.KitchenTicket{}, fmt.Errorf("%s: ticket %s was bumped, but its order wasn't updated, %w", op, ticketID, err)
+	}
+	return ticket, nil
+}
+
+// advanceOrder moves the order through the given statuses, skipping the ones it has reached already. An
+// order someone moved elsewhere in the meantime, e.g. cancelled it, is left alone.
+func (s *OrderService) advanceOrder(ctx context.Context, orderID string, statuses ...string) error {
+	order, err := s.OrderRepo.GetOrderById(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	current := order.Status
+	for _, status := range statuses {
+		if _, ok := orderTransitions[current][status]; !ok {
+			continue
+		}
+		if _, err := s.TransitionOrder(ctx, orderID, status); err != nil {
+			if errors.Is(err, ErrIllegalTransition) {
+				return nil
+			}
+			return err
+		}
+		current = status
+	}
+	return nil
+}
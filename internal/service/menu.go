@@ -1,29 +1,58 @@
 package service
 
 import (
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/units"
 	"cofee-shop-mongo/models"
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"time"
 )
 
 type MenuRepository interface {
 	CreateMenuItem(ctx context.Context, item models.MenuItem) (string, error)
-	GetAllMenuItems(ctx context.Context) ([]models.MenuItem, error)
+	GetAllMenuItems(ctx context.Context, includeArchived bool) ([]models.MenuItem, error)
+	ListMenuItems(ctx context.Context, filter models.MenuFilter, query models.ListQuery) (models.Page[models.MenuItem], error)
 	GetMenuItemById(ctx context.Context, MenuId string) (models.MenuItem, error)
-	DeleteMenuItemById(ctx context.Context, id string) error
+	GetMenuItemsByIds(ctx context.Context, ids []string) ([]models.MenuItem, error)
+	ArchiveMenuItemById(ctx context.Context, id, archivedAt string) error
+	RestoreMenuItemById(ctx context.Context, id string) error
 	UpdateMenuItemById(ctx context.Context, id string, item models.MenuItem) error
+	UpdateMenuItemPrice(ctx context.Context, id, variantID string, price float64) error
+	CountMenuItemsInCategory(ctx context.Context, categoryID string) (int64, error)
 }
 
 type MenuService struct {
-	Repo MenuRepository
+	Repo             MenuRepository
+	Categories       CategoryRepository
+	PriceChanges     PriceChangeRepository
+	InventoryService *InventoryService
+	Tx               Transactor
+	// Location is the shop's timezone, which menu schedules are read in.
+	Location *time.Location
 }
 
-func NewMenuService(repo MenuRepository) *MenuService {
-	return &MenuService{Repo: repo}
+func NewMenuService(repo MenuRepository, categories CategoryRepository, priceChanges PriceChangeRepository, inventoryService *InventoryService, tx Transactor, location *time.Location) *MenuService {
+	return &MenuService{
+		Repo:             repo,
+		Categories:       categories,
+		PriceChanges:     priceChanges,
+		InventoryService: inventoryService,
+		Tx:               tx,
+		Location:         location,
+	}
 }
 
 func (s *MenuService) CreateMenuItem(ctx context.Context, item models.MenuItem) (string, error) {
 	const op = "service.CreateMenuItem"
+	item.ArchivedAt = ""
+	if err := s.validateMenuItem(ctx, item); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
 	id, err := s.Repo.CreateMenuItem(ctx, item)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
@@ -31,14 +60,57 @@ func (s *MenuService) CreateMenuItem(ctx context.Context, item models.MenuItem)
 	return id, nil
 }
 
-func (s *MenuService) GetAllMenuItems(ctx context.Context) ([]models.MenuItem, error) {
+// GetAllMenuItems returns one page of the menu, with the availability, allergens and nutrition facts of
+// every item. The filters the database can't apply, whether an item is on sale at filter.At, available or
+// free of the excluded allergens, are applied here. Items are fetched until the page is full or the menu
+// ends, each time only as many as are still missing, so the cursor of the page points past the last item
+// that was looked at.
+func (s *MenuService) GetAllMenuItems(ctx context.Context, filter models.MenuFilter, query models.ListQuery) (models.Page[models.MenuItem], error) {
 	const op = "service.GetAllMenuItems"
 
-	items, err := s.Repo.GetAllMenuItems(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	page := models.Page[models.MenuItem]{Items: []models.MenuItem{}}
+	limit := query.Limit
+	for {
+		query.Limit = limit - len(page.Items)
+		fetched, err := s.Repo.ListMenuItems(ctx, filter, query)
+		if err != nil {
+			return models.Page[models.MenuItem]{}, fmt.Errorf("%s: %w", op, err)
+		}
+		items, err := s.filterMenuItems(ctx, fetched.Items, filter)
+		if err != nil {
+			return models.Page[models.MenuItem]{}, fmt.Errorf("%s: %w", op, err)
+		}
+		page.Items = append(page.Items, items...)
+		page.NextCursor = fetched.NextCursor
+		if len(page.Items) == limit || fetched.NextCursor == "" {
+			return page, nil
+		}
+		query.Cursor = fetched.NextCursor
 	}
+}
 
+// filterMenuItems works out the derived info of the items and drops the ones the filters the database
+// can't apply leave out.
+func (s *MenuService) filterMenuItems(ctx context.Context, items []models.MenuItem, filter models.MenuFilter) ([]models.MenuItem, error) {
+	at := filter.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	for i := range items {
+		items[i].OnSale = onSale(items[i].Schedule, at, s.Location)
+	}
+	if !filter.At.IsZero() {
+		items = slices.DeleteFunc(items, func(item models.MenuItem) bool { return !item.OnSale })
+	}
+	if err := s.setDerivedInfo(ctx, items); err != nil {
+		return nil, err
+	}
+	if filter.OnlyAvailable {
+		items = slices.DeleteFunc(items, func(item models.MenuItem) bool { return !item.Available })
+	}
+	if excluded := normalizeAllergens(filter.ExcludeAllergens); len(excluded) > 0 {
+		items = slices.DeleteFunc(items, func(item models.MenuItem) bool { return containsAnyAllergen(item.Allergens, excluded) })
+	}
 	return items, nil
 }
 
@@ -49,14 +121,22 @@ func (s *MenuService) GetMenuItemById(ctx context.Context, id string) (models.Me
 	if err != nil {
 		return models.MenuItem{}, fmt.Errorf("%s: %w", op, err)
 	}
+	items := []models.MenuItem{item}
+	if err := s.setDerivedInfo(ctx, items); err != nil {
+		return models.MenuItem{}, fmt.Errorf("%s: %w", op, err)
+	}
+	item = items[0]
+	item.OnSale = onSale(item.Schedule, time.Now(), s.Location)
 
 	return item, nil
 }
 
+// DeleteMenuItemById archives the menu item. It leaves the menu but stays readable by id, so that past
+// orders and reports still find it.
 func (s *MenuService) DeleteMenuItemById(ctx context.Context, id string) error {
 	const op = "service.DeleteMenuItemById"
 
-	err := s.Repo.DeleteMenuItemById(ctx, id)
+	err := s.Repo.ArchiveMenuItemById(ctx, id, time.Now().UTC().Format(time.RFC3339))
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -64,13 +144,323 @@ func (s *MenuService) DeleteMenuItemById(ctx context.Context, id string) error {
 	return nil
 }
 
+// RestoreMenuItemById puts an archived menu item back on the menu. Its category, bundle products and
+// ingredients must still be there.
+func (s *MenuService) RestoreMenuItemById(ctx context.Context, id string) error {
+	const op = "service.RestoreMenuItemById"
+
+	item, err := s.Repo.GetMenuItemById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.validateMenuItem(ctx, item); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.Repo.RestoreMenuItemById(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
 func (s *MenuService) UpdateMenuItemById(ctx context.Context, id string, item models.MenuItem) error {
 	const op = "service.UpdateMenuItemById"
 	item.ProductId = id
-	err := s.Repo.UpdateMenuItemById(ctx, id, item)
+	if err := s.validateMenuItem(ctx, item); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	err := s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.Repo.GetMenuItemById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.Repo.UpdateMenuItemById(ctx, id, item); err != nil {
+			return err
+		}
+		return s.recordPriceChanges(ctx, current, item)
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
+
+// stationName is what the name of a kitchen station may look like.
+var stationName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// validateMenuItem checks the item against the rest of the data: its category must exist and
+// the recipes of the item, its variants and its modifiers must only use stocked ingredients that
+// aren't archived.
+func (s *MenuService) validateMenuItem(ctx context.Context, item models.MenuItem) error {
+	if item.Station != "" && !stationName.MatchString(item.Station) {
+		return fmt.Errorf("%w: station %q must be lowercase letters, digits, '-' and '_'", ErrInvalidMenuItem, item.Station)
+	}
+	if item.CategoryID != "" {
+		if _, err := s.Categories.GetCategoryById(ctx, item.CategoryID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("%w: unknown category %q", ErrInvalidMenuItem, item.CategoryID)
+			}
+			return err
+		}
+	}
+	if err := s.validateComponents(ctx, item); err != nil {
+		return err
+	}
+	if err := s.validateRecipe(ctx, menuIngredients([]models.MenuItem{item})); err != nil {
+		return err
+	}
+	return s.validateReplacements(ctx, item)
+}
+
+// validateReplacements checks that the ingredients the modifiers of the item swap out are stocked.
+func (s *MenuService) validateReplacements(ctx context.Context, item models.MenuItem) error {
+	var replaced []models.MenuItemIngredient
+	for _, group := range item.ModifierGroups {
+		for _, modifier := range group.Modifiers {
+			for _, change := range modifier.Ingredients {
+				if change.Replaces != "" {
+					replaced = append(replaced, models.MenuItemIngredient{IngredientID: change.Replaces})
+				}
+			}
+		}
+	}
+	if len(replaced) == 0 {
+		return nil
+	}
+	stocked, err := s.InventoryService.GetInventoryItemsByIngredients(ctx, replaced)
+	if err != nil {
+		return err
+	}
+	for _, ingredient := range replaced {
+		if _, ok := stocked[ingredient.IngredientID]; !ok {
+			return fmt.Errorf("%w: unknown ingredient %q", ErrInvalidMenuItem, ingredient.IngredientID)
+		}
+	}
+	return nil
+}
+
+// validateComponents checks that the products offered in the slots of a bundle exist, aren't bundles
+// themselves and come in the variant the slot asks for.
+func (s *MenuService) validateComponents(ctx context.Context, item models.MenuItem) error {
+	for _, slot := range item.Components {
+		for _, productID := range slot.ProductIDs {
+			if productID == item.ProductId {
+				return fmt.Errorf("%w: a bundle can't contain itself", ErrInvalidMenuItem)
+			}
+			component, err := s.Repo.GetMenuItemById(ctx, productID)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return fmt.Errorf("%w: unknown product %q in slot %q", ErrInvalidMenuItem, productID, slot.SlotID)
+				}
+				return err
+			}
+			if component.ArchivedAt != "" {
+				return fmt.Errorf("%w: %q in slot %q is archived", ErrInvalidMenuItem, productID, slot.SlotID)
+			}
+			if len(component.Components) > 0 {
+				return fmt.Errorf("%w: %q in slot %q is a bundle itself", ErrInvalidMenuItem, productID, slot.SlotID)
+			}
+			if slot.VariantID == "" {
+				continue
+			}
+			if _, err := resolveVariant(component, slot.VariantID); err != nil {
+				return fmt.Errorf("%w: %q in slot %q doesn't come as %q", ErrInvalidMenuItem, productID, slot.SlotID, slot.VariantID)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRecipe checks that every ingredient of the recipe is stocked and not archived, and in a unit its
+// quantity can be converted from.
+func (s *MenuService) validateRecipe(ctx context.Context, ingredients []models.MenuItemIngredient) error {
+	_, err := s.InventoryService.InStockUnits(ctx, ingredients)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: unknown ingredient, %v", ErrInvalidMenuItem, err)
+	}
+	if errors.Is(err, units.ErrIncompatibleUnits) || errors.Is(err, units.ErrUnknownUnit) {
+		return fmt.Errorf("%w: %w", ErrInvalidMenuItem, err)
+	}
+	if err != nil {
+		return err
+	}
+	stocked, err := s.InventoryService.GetInventoryItemsByIngredients(ctx, ingredients)
+	if err != nil {
+		return err
+	}
+	for _, ingredient := range ingredients {
+		if stocked[ingredient.IngredientID].ArchivedAt != "" {
+			return fmt.Errorf("%w: ingredient %q is archived", ErrInvalidMenuItem, ingredient.IngredientID)
+		}
+	}
+	return nil
+}
+
+// setDerivedInfo works out what the items' ingredients say about them: how many servings of every item
+// and variant the stock that isn't reserved yet is enough for, and their allergens and nutrition facts.
+// An item with variants is available while any of them is, and a bundle while every slot has a product
+// that is. The inventory of all the items is fetched in one query.
+func (s *MenuService) setDerivedInfo(ctx context.Context, items []models.MenuItem) error {
+	components, err := s.bundleComponents(ctx, items)
+	if err != nil {
+		return err
+	}
+
+	stocked, err := s.InventoryService.GetInventoryItemsByIngredients(ctx, menuIngredients(append(slices.Clone(items), components...)))
+	if err != nil {
+		return err
+	}
+
+	byId := make(map[string]models.MenuItem, len(components))
+	for _, component := range components {
+		setServings(&component, stocked)
+		setDietaryInfo(&component, stocked)
+		byId[component.ProductId] = component
+	}
+	for i := range items {
+		if len(items[i].Components) == 0 {
+			setServings(&items[i], stocked)
+			setDietaryInfo(&items[i], stocked)
+		}
+	}
+	for i := range items {
+		if len(items[i].Components) > 0 {
+			items[i].MaxServings = bundleServings(items[i], byId)
+			items[i].Available = items[i].MaxServings > 0
+			items[i].Allergens = bundleAllergens(items[i], byId)
+		}
+	}
+	return nil
+}
+
+// menuIngredients lists every ingredient the items, their variants and modifiers use.
+func menuIngredients(items []models.MenuItem) []models.MenuItemIngredient {
+	var ingredients []models.MenuItemIngredient
+	for _, item := range items {
+		ingredients = append(ingredients, item.Ingredients...)
+		for _, variant := range item.Variants {
+			ingredients = append(ingredients, variant.Ingredients...)
+		}
+		for _, group := range item.ModifierGroups {
+			for _, modifier := range group.Modifiers {
+				for _, change := range modifier.Ingredients {
+					if change.IngredientID != "" {
+						ingredients = append(ingredients, models.MenuItemIngredient{IngredientID: change.IngredientID, Quantity: change.Quantity, Unit: change.Unit})
+					}
+				}
+			}
+		}
+	}
+	return ingredients
+}
+
+// bundleComponents returns every menu item the bundles among items are made of, fetched in one query.
+func (s *MenuService) bundleComponents(ctx context.Context, items []models.MenuItem) ([]models.MenuItem, error) {
+	var ids []string
+	for _, item := range items {
+		for _, slot := range item.Components {
+			for _, productID := range slot.ProductIDs {
+				if !slices.Contains(ids, productID) {
+					ids = append(ids, productID)
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.Repo.GetMenuItemsByIds(ctx, ids)
+}
+
+func setServings(item *models.MenuItem, stocked map[string]models.InventoryItem) {
+	if len(item.Variants) == 0 {
+		item.MaxServings = maxServings(item.Ingredients, stocked)
+	} else {
+		item.MaxServings = 0
+		for j := range item.Variants {
+			variant := &item.Variants[j]
+			variant.MaxServings = maxServings(variant.Ingredients, stocked)
+			variant.Available = variant.MaxServings > 0
+			item.MaxServings = max(item.MaxServings, variant.MaxServings)
+		}
+	}
+	item.Available = item.MaxServings > 0
+}
+
+// bundleServings returns how many of the bundle can be made, going by the best product of every slot.
+// Slots are counted on their own, so components sharing an ingredient can make it come out too high.
+func bundleServings(bundle models.MenuItem, components map[string]models.MenuItem) int {
+	servings := math.MaxInt
+	for _, slot := range bundle.Components {
+		best := 0
+		for _, productID := range slot.ProductIDs {
+			component, ok := components[productID]
+			if !ok {
+				continue
+			}
+			n := component.MaxServings
+			if slot.VariantID != "" {
+				n = 0
+				if j := slices.IndexFunc(component.Variants, func(variant models.MenuItemVariant) bool { return variant.VariantID == slot.VariantID }); j >= 0 {
+					n = component.Variants[j].MaxServings
+				}
+			}
+			best = max(best, n/max(slot.Quantity, 1))
+		}
+		servings = min(servings, best)
+	}
+	if servings == math.MaxInt {
+		return 0
+	}
+	return servings
+}
+
+// resolveVariant returns the variant of the item that was ordered. Items without variants are
+// ordered as they are, as if they had a single variant with the item's own price and recipe.
+func resolveVariant(item models.MenuItem, variantID string) (models.MenuItemVariant, error) {
+	if len(item.Variants) == 0 {
+		if variantID != "" {
+			return models.MenuItemVariant{}, fmt.Errorf("%w: %s has no variant %q", ErrInvalidOrder, item.ProductId, variantID)
+		}
+		return models.MenuItemVariant{Name: item.Name, Price: item.Price, Ingredients: item.Ingredients}, nil
+	}
+	if variantID == "" {
+		return models.MenuItemVariant{}, fmt.Errorf("%w: %s needs a variant", ErrInvalidOrder, item.ProductId)
+	}
+	i := slices.IndexFunc(item.Variants, func(variant models.MenuItemVariant) bool { return variant.VariantID == variantID })
+	if i < 0 {
+		return models.MenuItemVariant{}, fmt.Errorf("%w: %s has no variant %q", ErrInvalidOrder, item.ProductId, variantID)
+	}
+	variant := item.Variants[i]
+	variant.Name = item.Name + " (" + variant.Name + ")"
+	return variant, nil
+}
+
+// maxServings returns how many times the recipe can be made from stock. A recipe using an ingredient
+// that isn't stocked, or in a unit that can't be converted, can't be made at all.
+func maxServings(recipe []models.MenuItemIngredient, stocked map[string]models.InventoryItem) int {
+	if len(recipe) == 0 {
+		return 0
+	}
+	servings := math.MaxInt
+	for _, ingredient := range recipe {
+		item, ok := stocked[ingredient.IngredientID]
+		if !ok {
+			return 0
+		}
+		needed := ingredient.Quantity
+		if ingredient.Unit != "" {
+			var err error
+			if needed, err = units.Convert(ingredient.Quantity, ingredient.Unit, item.Unit); err != nil {
+				return 0
+			}
+		}
+		if needed <= 0 {
+			return 0
+		}
+		// the epsilon keeps float error from costing a serving, e.g. 0.3/0.1 = 2.9999999999999996
+		servings = min(servings, int(math.Floor((item.Quantity-item.Reserved)/needed+1e-9)))
+	}
+	return max(servings, 0)
+}
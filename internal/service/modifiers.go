@@ -0,0 +1,80 @@
+package service
+
+import (
+	"cofee-shop-mongo/models"
+	"fmt"
+	"slices"
+)
+
+// selectModifiers checks the modifiers picked for an order item against the modifier groups of the
+// menu item and returns them with their current names and price deltas, together with their definitions.
+func selectModifiers(item models.MenuItem, selections []models.OrderItemModifier) ([]models.OrderItemModifier, []models.Modifier, error) {
+	selected := make([]models.OrderItemModifier, 0, len(selections))
+	modifiers := make([]models.Modifier, 0, len(selections))
+	picked := make(map[string]int)
+
+	for _, selection := range selections {
+		g := slices.IndexFunc(item.ModifierGroups, func(group models.ModifierGroup) bool { return group.GroupID == selection.GroupID })
+		if g < 0 {
+			return nil, nil, fmt.Errorf("%w: %s has no modifier group %q", ErrInvalidOrder, item.ProductId, selection.GroupID)
+		}
+		group := item.ModifierGroups[g]
+		m := slices.IndexFunc(group.Modifiers, func(modifier models.Modifier) bool { return modifier.ModifierID == selection.ModifierID })
+		if m < 0 {
+			return nil, nil, fmt.Errorf("%w: %s has no modifier %q in %q", ErrInvalidOrder, item.ProductId, selection.ModifierID, group.Name)
+		}
+		if slices.ContainsFunc(selected, func(other models.OrderItemModifier) bool {
+			return other.GroupID == selection.GroupID && other.ModifierID == selection.ModifierID
+		}) {
+			return nil, nil, fmt.Errorf("%w: %q is picked more than once", ErrInvalidOrder, group.Modifiers[m].Name)
+		}
+		picked[group.GroupID]++
+
+		modifier := group.Modifiers[m]
+		selected = append(selected, models.OrderItemModifier{
+			GroupID:    group.GroupID,
+			ModifierID: modifier.ModifierID,
+			Name:       modifier.Name,
+			PriceDelta: modifier.PriceDelta,
+		})
+		modifiers = append(modifiers, modifier)
+	}
+
+	for _, group := range item.ModifierGroups {
+		if group.Required && picked[group.GroupID] == 0 {
+			return nil, nil, fmt.Errorf("%w: %s needs a choice of %q", ErrInvalidOrder, item.ProductId, group.Name)
+		}
+		if group.MaxSelections > 0 && picked[group.GroupID] > group.MaxSelections {
+			return nil, nil, fmt.Errorf("%w: at most %d of %q can be picked for %s", ErrInvalidOrder, group.MaxSelections, group.Name, item.ProductId)
+		}
+	}
+	return selected, modifiers, nil
+}
+
+// applyModifiers returns the recipe with the substitutions and additions of the modifiers made.
+func applyModifiers(recipe []models.MenuItemIngredient, modifiers []models.Modifier) []models.MenuItemIngredient {
+	recipe = slices.Clone(recipe)
+	for _, modifier := range modifiers {
+		for _, change := range modifier.Ingredients {
+			if change.Replaces == "" {
+				recipe = append(recipe, models.MenuItemIngredient{IngredientID: change.IngredientID, Quantity: change.Quantity, Unit: change.Unit})
+				continue
+			}
+			i := slices.IndexFunc(recipe, func(ingredient models.MenuItemIngredient) bool { return ingredient.IngredientID == change.Replaces })
+			if i < 0 {
+				continue
+			}
+			replaced := recipe[i]
+			recipe = slices.Delete(recipe, i, i+1)
+			if change.IngredientID == "" {
+				continue
+			}
+			substitute := models.MenuItemIngredient{IngredientID: change.IngredientID, Quantity: change.Quantity, Unit: change.Unit}
+			if substitute.Quantity == 0 {
+				substitute.Quantity, substitute.Unit = replaced.Quantity, replaced.Unit
+			}
+			recipe = append(recipe, substitute)
+		}
+	}
+	return recipe
+}
@@ -1,55 +1,185 @@
 package service
 
 import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/units"
 	"cofee-shop-mongo/models"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 )
 
 type OrderRepository interface {
 	CreateOrder(ctx context.Context, item models.Order) (string, error)
-	GetAllOrders(ctx context.Context) ([]models.Order, error)
+	ListOrders(ctx context.Context, filter models.OrderFilter, query models.ListQuery) (models.Page[models.Order], error)
 	GetOrderById(ctx context.Context, OrderId string) (models.Order, error)
-	UpdateOrderById(ctx context.Context, OrderId string, item models.Order) error
+	UpdateOrderById(ctx context.Context, OrderId, status, stock string, item models.Order) error
 	DeleteOrderById(ctx context.Context, OrderId string) error
+	UpdateOrderStatus(ctx context.Context, OrderId, from string, change models.StatusChange) error
+	UpdateOrderItems(ctx context.Context, OrderId string, items []models.OrderItem) error
+	AddOrderRefund(ctx context.Context, OrderId string, items []models.OrderItem, refund models.Refund) error
+	UpdateOrderStock(ctx context.Context, OrderId, from, to string) error
+	GetOrdersWithExpiredReservations(ctx context.Context, before string) ([]models.Order, error)
+	GetOrdersByStatus(ctx context.Context, statuses []string, since string) ([]models.Order, error)
+}
+
+type TicketRepository interface {
+	CreateTickets(ctx context.Context, tickets []models.KitchenTicket) error
+	GetTicketById(ctx context.Context, id string) (models.KitchenTicket, error)
+	GetTickets(ctx context.Context, filter models.TicketFilter) ([]models.KitchenTicket, error)
+	UpdateTicketStatus(ctx context.Context, id, from, to, at string) error
+	CountOpenTickets(ctx context.Context, orderID string) (int64, error)
+	DeleteOpenTickets(ctx context.Context, orderID string) error
+	DeleteOrderTickets(ctx context.Context, orderID string) error
+}
+
+// OrderEventPublisher is told about every order that is placed or moves to another status.
+type OrderEventPublisher interface {
+	Publish(ctx context.Context, event models.OrderEvent)
+}
+
+type CounterRepository interface {
+	NextSequence(ctx context.Context, name string, expiresAt time.Time) (int64, error)
+}
+
+// orderTransitions lists, for every status, the statuses an order may move to next
+// and the roles allowed to make that move.
+var orderTransitions = map[string]map[string][]string{
+	models.OrderStatusPending: {
+		models.OrderStatusAccepted:  models.StaffAccess,
+		models.OrderStatusCancelled: models.ClientAccess,
+	},
+	models.OrderStatusAccepted: {
+		models.OrderStatusInPreparation: models.StaffAccess,
+		models.OrderStatusCancelled:     models.StaffAccess,
+	},
+	models.OrderStatusInPreparation: {
+		models.OrderStatusReady:     models.StaffAccess,
+		models.OrderStatusCancelled: models.StaffAccess,
+	},
+	models.OrderStatusReady: {
+		models.OrderStatusPickedUp:  models.StaffAccess,
+		models.OrderStatusCancelled: models.StaffAccess,
+	},
+	models.OrderStatusPickedUp: {
+		models.OrderStatusRefunded: models.StaffAccess,
+	},
+	models.OrderStatusCancelled: {},
+	models.OrderStatusRefunded:  {},
 }
 
 type OrderService struct {
 	OrderRepo        OrderRepository
+	Counters         CounterRepository
+	Tickets          TicketRepository
 	MenuService      *MenuService
 	InventoryService *InventoryService
+	Tx               Transactor
+	// ReservationTTL is how long the ingredients of a new order are held for it, zero turns reservations off.
+	ReservationTTL time.Duration
+	// Events gets the order events, it is nil when they come from a change stream instead.
+	Events OrderEventPublisher
 }
 
-func NewOrderService(OrderRepo OrderRepository, MenuService *MenuService, InventoryService *InventoryService) *OrderService {
-	return &OrderService{OrderRepo, MenuService, InventoryService}
+func NewOrderService(OrderRepo OrderRepository, Counters CounterRepository, Tickets TicketRepository, MenuService *MenuService, InventoryService *InventoryService, Tx Transactor, ReservationTTL time.Duration, Events OrderEventPublisher) *OrderService {
+	return &OrderService{OrderRepo, Counters, Tickets, MenuService, InventoryService, Tx, ReservationTTL, Events}
 }
 
-func (s *OrderService) CreateOrder(ctx context.Context, order models.Order) (string, error) {
+// CreateOrder places the order and returns it along with its access token. The order id and pickup number
+// are generated, whatever the client sent is ignored, and only once the order was checked and its stock
+// reserved, so orders that are turned down don't use up pickup numbers. Orders placed by a client belong
+// to them, the token lets guests see and cancel theirs.
+func (s *OrderService) CreateOrder(ctx context.Context, order models.Order) (created models.Order, accessToken string, err error) {
 	const op = "service.CreateOrder"
 
-	order.Status = "open"
-	order.CreatedAt = time.Now().Format(time.RFC3339)
+	now := time.Now().UTC().Format(time.RFC3339)
+	order, err = s.priceOrder(ctx, order)
+	if err != nil {
+		return models.Order{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	order.CustomerID = ""
+	if auth.RoleFromContext(ctx) == models.RoleClient {
+		order.CustomerID = auth.UserIDFromContext(ctx)
+	}
+	accessToken, err = auth.NewAccessToken()
+	if err != nil {
+		return models.Order{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	order.AccessTokenHash = auth.HashAccessToken(accessToken)
+	order.Refunds = nil
+	order.RefundedAmount = 0
+	order.Status = models.OrderStatusPending
+	order.StatusHistory = []models.StatusChange{{
+		Status:    models.OrderStatusPending,
+		ChangedBy: auth.UserIDFromContext(ctx),
+		ChangedAt: now,
+	}}
+	order.CreatedAt = now
+	order.Stock = ""
+	order.ReservedUntil = ""
+
+	if s.ReservationTTL == 0 {
+		order.ProductId, order.PickupNumber, err = s.nextOrderNumber(ctx)
+		if err != nil {
+			return models.Order{}, "", fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := s.OrderRepo.CreateOrder(ctx, order); err != nil {
+			return models.Order{}, "", fmt.Errorf("%s: failed to create order, %w", op, err)
+		}
+		s.publish(ctx, models.OrderEventCreated, order)
+		return order, accessToken, nil
+	}
 
-	orderID, err := s.OrderRepo.CreateOrder(ctx, order)
+	order, err = s.reserveOrder(ctx, order)
 	if err != nil {
-		return "", fmt.Errorf("%s: failed to create order, %w", op, err)
+		return models.Order{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	err = s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := applyStock(ctx, outstandingIngredients(order.Items), s.InventoryService.ReserveStock); err != nil {
+			return fmt.Errorf("failed to reserve stock, %w", err)
+		}
+		// the counter takes part in the transaction, an order that is turned down gives its number back
+		var err error
+		order.ProductId, order.PickupNumber, err = s.nextOrderNumber(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := s.OrderRepo.CreateOrder(ctx, order); err != nil {
+			return fmt.Errorf("failed to create order, %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	return orderID, nil
+	s.publish(ctx, models.OrderEventCreated, order)
+	return order, accessToken, nil
 }
 
-func (s *OrderService) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+// GetAllOrders returns one page of the orders matching the filter, clients only get their own.
+func (s *OrderService) GetAllOrders(ctx context.Context, filter models.OrderFilter, query models.ListQuery) (models.Page[models.Order], error) {
 	const op = "service.GetAllOrders"
 
-	orders, err := s.OrderRepo.GetAllOrders(ctx)
+	if !slices.Contains(models.StaffAccess, auth.RoleFromContext(ctx)) {
+		filter.CustomerID = auth.UserIDFromContext(ctx)
+		if filter.CustomerID == "" {
+			return models.Page[models.Order]{Items: []models.Order{}}, nil
+		}
+	}
+
+	orders, err := s.OrderRepo.ListOrders(ctx, filter, query)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.Page[models.Order]{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return orders, nil
 }
 
+// GetOrderById returns the order if the caller may see it, orders of others are reported as not found.
 func (s *OrderService) GetOrderById(ctx context.Context, orderId string) (models.Order, error) {
 	const op = "service.GetOrderById"
 
@@ -57,14 +187,63 @@ func (s *OrderService) GetOrderById(ctx context.Context, orderId string) (models
 	if err != nil {
 		return models.Order{}, fmt.Errorf("%s: %w", op, err)
 	}
+	if !canAccessOrder(ctx, order) {
+		return models.Order{}, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
 
 	return order, nil
 }
 
+// UpdateOrderById replaces the items of a pending order. The order is read again and written within one
+// transaction that only touches it while it is still pending, so an order accepted in the meantime is left alone.
 func (s *OrderService) UpdateOrderById(ctx context.Context, orderId string, order models.Order) error {
 	const op = "service.UpdateOrderById"
 
-	err := s.OrderRepo.UpdateOrderById(ctx, orderId, order)
+	current, err := s.OrderRepo.GetOrderById(ctx, orderId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if current.Status != models.OrderStatusPending {
+		return fmt.Errorf("%s: %w: %s is %s", op, ErrOrderNotEditable, orderId, current.Status)
+	}
+
+	order, err = s.priceOrder(ctx, order)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	err = s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.OrderRepo.GetOrderById(ctx, orderId)
+		if err != nil {
+			return err
+		}
+		if current.Status != models.OrderStatusPending {
+			return fmt.Errorf("%w: %s is %s", ErrOrderNotEditable, orderId, current.Status)
+		}
+
+		updated := order
+		updated.CreatedAt = current.CreatedAt
+		updated.Stock = current.Stock
+		updated.ReservedUntil = current.ReservedUntil
+		if current.Stock == models.StockReserved {
+			// swap the reservation of the old items for one of the new items
+			updated, err = s.reserveOrder(ctx, updated)
+			if err != nil {
+				return err
+			}
+			if err := applyStock(ctx, outstandingIngredients(current.Items), s.InventoryService.ReleaseStock); err != nil {
+				return fmt.Errorf("failed to release stock, %w", err)
+			}
+			if err := applyStock(ctx, outstandingIngredients(updated.Items), s.InventoryService.ReserveStock); err != nil {
+				return fmt.Errorf("failed to reserve stock, %w", err)
+			}
+		}
+
+		err = s.OrderRepo.UpdateOrderById(ctx, orderId, current.Status, current.Stock, updated)
+		if errors.Is(err, repository.ErrConflict) {
+			return fmt.Errorf("%w: order %s was changed concurrently", ErrOrderNotEditable, orderId)
+		}
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -75,7 +254,25 @@ func (s *OrderService) UpdateOrderById(ctx context.Context, orderId string, orde
 func (s *OrderService) DeleteOrderById(ctx context.Context, orderId string) error {
 	const op = "service.DeleteOrderById"
 
-	err := s.OrderRepo.DeleteOrderById(ctx, orderId)
+	order, err := s.OrderRepo.GetOrderById(ctx, orderId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if order.Stock == models.StockReserved {
+			if err := s.OrderRepo.UpdateOrderStock(ctx, orderId, order.Stock, models.StockReleased); err != nil {
+				return fmt.Errorf("failed to release reservation, %w", err)
+			}
+			if err := applyStock(ctx, outstandingIngredients(order.Items), s.InventoryService.ReleaseStock); err != nil {
+				return fmt.Errorf("failed to release stock, %w", err)
+			}
+		}
+		if err := s.Tickets.DeleteOrderTickets(ctx, orderId); err != nil {
+			return fmt.Errorf("failed to remove kitchen tickets, %w", err)
+		}
+		return s.OrderRepo.DeleteOrderById(ctx, orderId)
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -83,46 +280,284 @@ func (s *OrderService) DeleteOrderById(ctx context.Context, orderId string) erro
 	return nil
 }
 
+// CloseOrderById hands the order over to the customer, see TransitionOrder.
 func (s *OrderService) CloseOrderById(ctx context.Context, orderId string) error {
 	const op = "service.CloseOrderById"
 
+	_, err := s.TransitionOrder(ctx, orderId, models.OrderStatusPickedUp)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TransitionOrder moves the order to the given status if orderTransitions allows it for the
+// caller's role, and records the change in the order's status history.
+// Moving an order to picked_up deducts the ingredients of every item, or what was reserved for
+// them, in the same transaction: if any ingredient is short, no stock is deducted and the order
+// keeps its status. Cancelling an order releases its reservation, picked up orders can't be
+// cancelled, and moving one to refunded is a full refund, see RefundOrder.
+// Accepting an order splits it into the tickets of the kitchen stations, cancelling it takes the tickets that
+// aren't done off the stations.
+func (s *OrderService) TransitionOrder(ctx context.Context, orderId, to string) (models.Order, error) {
+	const op = "service.TransitionOrder"
+
+	if _, ok := orderTransitions[to]; !ok {
+		return models.Order{}, fmt.Errorf("%s: %w: %q", op, ErrUnknownStatus, to)
+	}
+	if to == models.OrderStatusRefunded {
+		order, err := s.RefundOrder(ctx, orderId, models.RefundPayload{})
+		if err != nil {
+			return models.Order{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return order, nil
+	}
+
 	order, err := s.OrderRepo.GetOrderById(ctx, orderId)
 	if err != nil {
-		return fmt.Errorf("%s: order not found: %s, %w", op, orderId, err)
+		return models.Order{}, fmt.Errorf("%s: order not found: %s, %w", op, orderId, err)
+	}
+	if !canAccessOrder(ctx, order) {
+		return models.Order{}, fmt.Errorf("%s: order not found: %s, %w", op, orderId, repository.ErrNotFound)
+	}
+
+	roles, ok := orderTransitions[order.Status][to]
+	if !ok {
+		return models.Order{}, fmt.Errorf("%s: %w: %s -> %s", op, ErrIllegalTransition, order.Status, to)
+	}
+	role := auth.RoleFromContext(ctx)
+	if role == "" {
+		// a guest holding the order's access token acts as its client
+		role = models.RoleClient
+	}
+	if !slices.Contains(roles, role) {
+		return models.Order{}, fmt.Errorf("%s: %w: %s -> %s", op, ErrTransitionForbidden, order.Status, to)
+	}
+
+	items := order.Items
+	stock := order.Stock
+	var deduct, commit, release map[string]float64
+	switch to {
+	case models.OrderStatusPickedUp:
+		if order.Stock == models.StockReserved {
+			commit = outstandingIngredients(items)
+		} else {
+			items, err = s.attachRecipes(ctx, order.Items)
+			if err != nil {
+				return models.Order{}, fmt.Errorf("%s: %w", op, err)
+			}
+			deduct = outstandingIngredients(items)
+		}
+		stock = models.StockDeducted
+	case models.OrderStatusCancelled:
+		if order.Stock == models.StockReserved {
+			release = outstandingIngredients(items)
+			stock = models.StockReleased
+		}
+	}
+
+	change := models.StatusChange{
+		From:      order.Status,
+		Status:    to,
+		ChangedBy: auth.UserIDFromContext(ctx),
+		ChangedAt: time.Now().Format(time.RFC3339),
+	}
+	err = s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		// flip the status first so a concurrent transition of the same order conflicts here
+		if err := s.OrderRepo.UpdateOrderStatus(ctx, orderId, order.Status, change); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return fmt.Errorf("%w: order %s was changed concurrently", ErrIllegalTransition, orderId)
+			}
+			return fmt.Errorf("failed to update order status, %w", err)
+		}
+		if stock != order.Stock {
+			// a reservation may have expired in the meantime
+			if err := s.OrderRepo.UpdateOrderStock(ctx, orderId, order.Stock, stock); err != nil {
+				if errors.Is(err, repository.ErrConflict) {
+					return fmt.Errorf("%w: order %s was changed concurrently", ErrIllegalTransition, orderId)
+				}
+				return fmt.Errorf("failed to update order stock, %w", err)
+			}
+		}
+		if deduct != nil {
+			if err := s.OrderRepo.UpdateOrderItems(ctx, orderId, items); err != nil {
+				return fmt.Errorf("failed to record deducted ingredients, %w", err)
+			}
+		}
+		if err := applyStock(ctx, deduct, recorded(s.InventoryService.DeductStock, models.MovementOrderDeduction, orderId)); err != nil {
+			return fmt.Errorf("failed to deduct stock, %w", err)
+		}
+		if err := applyStock(ctx, commit, recorded(s.InventoryService.CommitStock, models.MovementOrderDeduction, orderId)); err != nil {
+			return fmt.Errorf("failed to deduct reserved stock, %w", err)
+		}
+		if err := applyStock(ctx, release, s.InventoryService.ReleaseStock); err != nil {
+			return fmt.Errorf("failed to release stock, %w", err)
+		}
+		switch to {
+		case models.OrderStatusAccepted:
+			if err := s.Tickets.CreateTickets(ctx, kitchenTickets(order, change.ChangedAt)); err != nil {
+				return fmt.Errorf("failed to create kitchen tickets, %w", err)
+			}
+		case models.OrderStatusCancelled:
+			if err := s.Tickets.DeleteOpenTickets(ctx, orderId); err != nil {
+				return fmt.Errorf("failed to remove kitchen tickets, %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if order.Status == "closed" {
-		return fmt.Errorf("%s: order already closed: %s", op, orderId)
+	order.Stock = stock
+	order.Items = items
+	order.Status = to
+	order.StatusHistory = append(order.StatusHistory, change)
+	s.publish(ctx, models.OrderEventStatusChanged, order)
+	return order, nil
+}
+
+// publish tells Events about the order, once the transaction the change was made in has committed.
+func (s *OrderService) publish(ctx context.Context, eventType string, order models.Order) {
+	if s.Events == nil {
+		return
+	}
+	event := models.OrderEvent{
+		Type:         eventType,
+		OrderID:      order.ProductId,
+		PickupNumber: order.PickupNumber,
+		Status:       order.Status,
+		At:           time.Now().UTC().Format(time.RFC3339),
+		Order:        order,
+	}
+	repository.AfterCommit(ctx, func() {
+		s.Events.Publish(context.WithoutCancel(ctx), event)
+	})
+}
+
+// canAccessOrder reports whether the caller may see and act on the order: staff on every order, clients
+// on the orders they placed and anyone presenting the order's access token.
+func canAccessOrder(ctx context.Context, order models.Order) bool {
+	if slices.Contains(models.StaffAccess, auth.RoleFromContext(ctx)) {
+		return true
+	}
+	if userID := auth.UserIDFromContext(ctx); userID != "" && userID == order.CustomerID {
+		return true
 	}
+	return auth.VerifyAccessToken(order.AccessTokenHash, auth.OrderTokenFromContext(ctx))
+}
 
-	for _, item := range order.Items {
+// attachRecipes returns a copy of the items with the current recipe of each menu item, variant and modifiers attached,
+// its quantities converted into the units the ingredients are stocked in. Bundles get the recipes of their components.
+func (s *OrderService) attachRecipes(ctx context.Context, items []models.OrderItem) ([]models.OrderItem, error) {
+	withRecipes := make([]models.OrderItem, len(items))
+	for i, item := range items {
 		menuItem, err := s.MenuService.GetMenuItemById(ctx, item.ProductID)
 		if err != nil {
-			return fmt.Errorf("%s: %s, %w", op, item.ProductID, err)
+			return nil, fmt.Errorf("%s, %w", item.ProductID, err)
 		}
-
-		for _, ingredient := range menuItem.Ingredients {
-			if !s.InventoryService.HasSufficientStock(ctx, ingredient.IngredientID, ingredient.Quantity*float64(item.Quantity)) {
-				return fmt.Errorf("%s: insufficient stock for ingredient: %s", op, ingredient.IngredientID)
+		variant, err := resolveVariant(menuItem, item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		_, modifiers, err := selectModifiers(menuItem, item.Modifiers)
+		if err != nil {
+			return nil, err
+		}
+		recipe := variant.Ingredients
+		if len(item.Components) > 0 {
+			if recipe, err = s.componentRecipes(ctx, item.Components); err != nil {
+				return nil, err
 			}
 		}
+		item.Ingredients, err = s.InventoryService.InStockUnits(ctx, applyModifiers(recipe, modifiers))
+		if err != nil {
+			return nil, fmt.Errorf("%s, %w", item.ProductID, err)
+		}
+		withRecipes[i] = item
+	}
+	return withRecipes, nil
+}
+
+// priceOrder checks every item against the menu, snapshots the name and current price of the ordered variant and modifiers onto
+// the line and computes the order totals. Anything else the client sent on the items is dropped.
+func (s *OrderService) priceOrder(ctx context.Context, order models.Order) (models.Order, error) {
+	if len(order.Items) == 0 {
+		return models.Order{}, fmt.Errorf("%w: order must have at least one item", ErrInvalidOrder)
 	}
 
-	for _, item := range order.Items {
-		menuItem, _ := s.MenuService.GetMenuItemById(ctx, item.ProductID)
-		for _, ingredient := range menuItem.Ingredients {
-			err = s.InventoryService.DeductStock(ctx, ingredient.IngredientID, ingredient.Quantity*float64(item.Quantity))
-			if err != nil {
-				return fmt.Errorf("%s: failed to deduct stock for ingredient: %s, %w", op, ingredient.IngredientID, err)
+	items := make([]models.OrderItem, len(order.Items))
+	var subtotal float64
+	for i, item := range order.Items {
+		if item.Quantity <= 0 {
+			return models.Order{}, fmt.Errorf("%w: quantity for %s must be greater than zero", ErrInvalidOrder, item.ProductID)
+		}
+		menuItem, err := s.MenuService.GetMenuItemById(ctx, item.ProductID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return models.Order{}, fmt.Errorf("%w: unknown product %q", ErrInvalidOrder, item.ProductID)
 			}
+			return models.Order{}, fmt.Errorf("%s, %w", item.ProductID, err)
+		}
+		if menuItem.ArchivedAt != "" {
+			return models.Order{}, fmt.Errorf("%w: %s is no longer on the menu", ErrInvalidOrder, item.ProductID)
 		}
+		if !menuItem.OnSale {
+			return models.Order{}, fmt.Errorf("%w: %s is not on sale right now", ErrInvalidOrder, item.ProductID)
+		}
+		variant, err := resolveVariant(menuItem, item.VariantID)
+		if err != nil {
+			return models.Order{}, err
+		}
+		selected, _, err := selectModifiers(menuItem, item.Modifiers)
+		if err != nil {
+			return models.Order{}, err
+		}
+		unitPrice := variant.Price
+		for _, modifier := range selected {
+			unitPrice += modifier.PriceDelta
+		}
+		if unitPrice < 0 {
+			return models.Order{}, fmt.Errorf("%w: modifiers bring the price of %s below zero", ErrInvalidOrder, item.ProductID)
+		}
+		components, err := s.resolveComponents(ctx, menuItem, item.Components, unitPrice)
+		if err != nil {
+			return models.Order{}, err
+		}
+		items[i] = models.OrderItem{
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Quantity:   item.Quantity,
+			Modifiers:  selected,
+			Components: components,
+			Name:       variant.Name,
+			UnitPrice:  unitPrice,
+			Station:    menuItem.Station,
+		}
+		subtotal += unitPrice * float64(item.Quantity)
 	}
-
-	order.Status = "closed"
-	err = s.OrderRepo.UpdateOrderById(ctx, orderId, order)
-	if err != nil {
-		return fmt.Errorf("%s: failed to update order status, %w", op, err)
+	// the recipes are only read again when the order is closed, a recipe that can't be made should fail the order now
+	if _, err := s.attachRecipes(ctx, items); err != nil {
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, units.ErrIncompatibleUnits) || errors.Is(err, units.ErrUnknownUnit) {
+			return models.Order{}, fmt.Errorf("%w: the recipe of %v", ErrInvalidOrder, err)
+		}
+		return models.Order{}, err
 	}
 
-	return nil
+	order.Items = items
+	order.Subtotal = subtotal
+	order.Total = subtotal
+	return order, nil
+}
+
+// outstandingIngredients sums up the ingredients deducted for the items that were not refunded yet.
+func outstandingIngredients(items []models.OrderItem) map[string]float64 {
+	total := make(map[string]float64)
+	for _, item := range items {
+		for _, ingredient := range item.Ingredients {
+			total[ingredient.IngredientID] += ingredient.Quantity * float64(item.Quantity-item.RefundedQuantity)
+		}
+	}
+	return total
 }
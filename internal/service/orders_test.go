@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/service"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// testDatabase connects to the MongoDB in MONGO_TEST_URI and returns a fresh database that is dropped after
+// the test. Transactions need a replica set, the test is skipped without one.
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	var hello struct {
+		SetName string `bson:"setName"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		t.Fatalf("hello: %v", err)
+	}
+	if hello.SetName == "" {
+		t.Skip("MONGO_TEST_URI is not a replica set")
+	}
+
+	db := client.Database(fmt.Sprintf("cofee_shop_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() { db.Drop(context.Background()) })
+	if err := repository.EnsureIndexes(ctx, db); err != nil {
+		t.Fatalf("ensure indexes: %v", err)
+	}
+	return db
+}
+
+func TestCloseOrdersConcurrently(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.WithValue(context.Background(), auth.RoleKey, "staff")
+	ctx = context.WithValue(ctx, auth.UserIDKey, "barista")
+
+	tx := repository.NewTxManager(db)
+	menuRepository := repository.NewMenuRepository(db)
+	inventoryService := service.NewInventoryService(repository.NewInventoryRepository(db), repository.NewMovementRepository(db), menuRepository, repository.NewSupplierRepository(db), tx, nil)
+	menuService := service.NewMenuService(menuRepository, repository.NewCategoryRepository(db), repository.NewPriceChangeRepository(db), inventoryService, tx, time.UTC)
+	orderService := service.NewOrderService(repository.NewOrderRepository(db), repository.NewCounterRepository(db), repository.NewTicketRepository(db), menuService, inventoryService, tx, 0, nil)
+
+	const (
+		stock    = 100.0
+		perOrder = 18.0
+		orders   = 20
+	)
+	if _, err := inventoryService.CreateInventoryItem(ctx, models.InventoryItem{IngredientID: "beans", Name: "Espresso beans", Quantity: stock, Unit: "g"}); err != nil {
+		t.Fatalf("create inventory item: %v", err)
+	}
+	if _, err := menuService.CreateMenuItem(ctx, models.MenuItem{
+		ProductId:   "espresso",
+		Name:        "Espresso",
+		Price:       2.5,
+		Ingredients: []models.MenuItemIngredient{{IngredientID: "beans", Quantity: perOrder, Unit: "g"}},
+	}); err != nil {
+		t.Fatalf("create menu item: %v", err)
+	}
+
+	ids := make([]string, orders)
+	for i := range ids {
+		order, _, err := orderService.CreateOrder(ctx, models.Order{
+			CustomerName: fmt.Sprintf("customer %d", i),
+			Items:        []models.OrderItem{{ProductID: "espresso", Quantity: 1}},
+		})
+		if err != nil {
+			t.Fatalf("create order: %v", err)
+		}
+		for _, status := range []string{models.OrderStatusAccepted, models.OrderStatusInPreparation, models.OrderStatusReady} {
+			if _, err := orderService.TransitionOrder(ctx, order.ProductId, status); err != nil {
+				t.Fatalf("move order %s to %s: %v", order.ProductId, status, err)
+			}
+		}
+		ids[i] = order.ProductId
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, orders)
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = orderService.CloseOrderById(ctx, id)
+		}()
+	}
+	wg.Wait()
+
+	closed := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			closed++
+		case !errors.Is(err, service.ErrNotEnoughStock):
+			t.Errorf("close order %s: %v", ids[i], err)
+		}
+	}
+	if want := int(math.Floor(stock / perOrder)); closed != want {
+		t.Errorf("closed %d orders, want %d", closed, want)
+	}
+
+	item, err := inventoryService.GetInventoryItemById(ctx, "beans")
+	if err != nil {
+		t.Fatalf("get inventory item: %v", err)
+	}
+	if item.Quantity < 0 {
+		t.Fatalf("stock went negative: %v", item.Quantity)
+	}
+	if want := stock - perOrder*float64(closed); item.Quantity != want {
+		t.Errorf("stock is %v after closing %d orders, want %v", item.Quantity, closed, want)
+	}
+
+	for i, id := range ids {
+		order, err := orderService.GetOrderById(ctx, id)
+		if err != nil {
+			t.Fatalf("get order %s: %v", id, err)
+		}
+		want := models.OrderStatusPickedUp
+		if errs[i] != nil {
+			want = models.OrderStatusReady
+		}
+		if order.Status != want {
+			t.Errorf("order %s is %s, want %s", id, order.Status, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// nextOrderNumber generates the id and pickup number of a new order from the counter of the current shop
+// day, so that pickup numbers start over at #001 every morning. The id carries the day and is unique.
+func (s *OrderService) nextOrderNumber(ctx context.Context) (orderID, pickupNumber string, err error) {
+	today := shopDay(time.Now(), s.MenuService.Location)
+	// keep the counter a day longer than it is used, the shop day may end after midnight UTC
+	seq, err := s.Counters.NextSequence(ctx, "pickup:"+today.Format(time.DateOnly), today.AddDate(0, 0, 2))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to number the order, %w", err)
+	}
+	return fmt.Sprintf("ORD-%s-%03d", today.Format("20060102"), seq), fmt.Sprintf("#%03d", seq), nil
+}
+
+// GetPickupBoard returns the pickup numbers of today's orders that are being prepared or are ready.
+func (s *OrderService) GetPickupBoard(ctx context.Context) (models.PickupBoard, error) {
+	const op = "service.GetPickupBoard"
+
+	since := shopDay(time.Now(), s.MenuService.Location).UTC().Format(time.RFC3339)
+	statuses := []string{models.OrderStatusAccepted, models.OrderStatusInPreparation, models.OrderStatusReady}
+	orders, err := s.OrderRepo.GetOrdersByStatus(ctx, statuses, since)
+	if err != nil {
+		return models.PickupBoard{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	board := models.PickupBoard{Preparing: []string{}, Ready: []string{}}
+	for _, order := range orders {
+		if order.PickupNumber == "" {
+			continue
+		}
+		if order.Status == models.OrderStatusReady {
+			board.Ready = append(board.Ready, order.PickupNumber)
+		} else {
+			board.Preparing = append(board.Preparing, order.PickupNumber)
+		}
+	}
+	return board, nil
+}
+
+// shopDay returns the start of the day t falls on in the shop's timezone.
+func shopDay(t time.Time, location *time.Location) time.Time {
+	t = t.In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type PriceChangeRepository interface {
+	CreatePriceChange(ctx context.Context, change models.PriceChange) (string, error)
+	GetPriceChangeById(ctx context.Context, id string) (models.PriceChange, error)
+	GetPriceChangesByProduct(ctx context.Context, productID string) ([]models.PriceChange, error)
+	GetDuePriceChanges(ctx context.Context, before string) ([]models.PriceChange, error)
+	UpdatePriceChange(ctx context.Context, id, from string, change models.PriceChange) error
+}
+
+// GetPriceHistory returns the applied, scheduled and cancelled price changes of the menu item.
+func (s *MenuService) GetPriceHistory(ctx context.Context, id string) ([]models.PriceChange, error) {
+	const op = "service.GetPriceHistory"
+	if _, err := s.Repo.GetMenuItemById(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	changes, err := s.PriceChanges.GetPriceChangesByProduct(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return changes, nil
+}
+
+// SchedulePriceChange sets a new price for the menu item, or one of its variants, that takes effect
+// at a future time. ApplyDuePriceChanges puts it into effect.
+func (s *MenuService) SchedulePriceChange(ctx context.Context, id string, payload models.PriceChangePayload) (models.PriceChange, error) {
+	const op = "service.SchedulePriceChange"
+
+	item, err := s.Repo.GetMenuItemById(ctx, id)
+	if err != nil {
+		return models.PriceChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, ok := currentPrice(item, payload.VariantID); !ok {
+		if payload.VariantID == "" {
+			return models.PriceChange{}, fmt.Errorf("%s: %w: %s is priced per variant, name the variant", op, ErrInvalidPriceChange, id)
+		}
+		return models.PriceChange{}, fmt.Errorf("%s: %w: %s has no variant %q", op, ErrInvalidPriceChange, id, payload.VariantID)
+	}
+	if payload.Price <= 0 {
+		return models.PriceChange{}, fmt.Errorf("%s: %w: price must be greater than zero", op, ErrInvalidPriceChange)
+	}
+	effectiveAt, err := time.Parse(time.RFC3339, payload.EffectiveAt)
+	if err != nil {
+		return models.PriceChange{}, fmt.Errorf("%s: %w: effective_at must be an RFC3339 timestamp", op, ErrInvalidPriceChange)
+	}
+	if !effectiveAt.After(time.Now()) {
+		return models.PriceChange{}, fmt.Errorf("%s: %w: effective_at must be in the future", op, ErrInvalidPriceChange)
+	}
+
+	change := models.PriceChange{
+		ChangeID:    "PC-" + utils.GenerateRandomString(8),
+		ProductID:   id,
+		VariantID:   payload.VariantID,
+		NewPrice:    payload.Price,
+		Status:      models.PriceChangeScheduled,
+		ChangedBy:   auth.UserIDFromContext(ctx),
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		EffectiveAt: effectiveAt.UTC().Format(time.RFC3339),
+	}
+	if _, err := s.PriceChanges.CreatePriceChange(ctx, change); err != nil {
+		return models.PriceChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return change, nil
+}
+
+// CancelPriceChange cancels a price change of the menu item that hasn't taken effect yet.
+func (s *MenuService) CancelPriceChange(ctx context.Context, id, changeID string) error {
+	const op = "service.CancelPriceChange"
+
+	change, err := s.PriceChanges.GetPriceChangeById(ctx, changeID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if change.ProductID != id {
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	change.Status = models.PriceChangeCancelled
+	if err := s.PriceChanges.UpdatePriceChange(ctx, changeID, models.PriceChangeScheduled, change); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return fmt.Errorf("%s: %w: only scheduled price changes can be cancelled", op, ErrIllegalTransition)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ApplyDuePriceChanges puts the scheduled price changes whose time has come into effect and returns how
+// many it applied. A change whose variant was removed in the meantime is cancelled instead.
+func (s *MenuService) ApplyDuePriceChanges(ctx context.Context) (int, error) {
+	const op = "service.ApplyDuePriceChanges"
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	changes, err := s.PriceChanges.GetDuePriceChanges(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	applied := 0
+	for _, change := range changes {
+		err := s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+			item, err := s.Repo.GetMenuItemById(ctx, change.ProductID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return err
+			}
+			oldPrice, ok := currentPrice(item, change.VariantID)
+			if !ok {
+				change.Status = models.PriceChangeCancelled
+				return s.PriceChanges.UpdatePriceChange(ctx, change.ChangeID, models.PriceChangeScheduled, change)
+			}
+			if err := s.Repo.UpdateMenuItemPrice(ctx, change.ProductID, change.VariantID, change.NewPrice); err != nil {
+				return err
+			}
+			change.OldPrice = oldPrice
+			change.Status = models.PriceChangeApplied
+			change.AppliedAt = now
+			return s.PriceChanges.UpdatePriceChange(ctx, change.ChangeID, models.PriceChangeScheduled, change)
+		})
+		if err != nil {
+			// the change was cancelled in the meantime
+			if errors.Is(err, repository.ErrConflict) {
+				continue
+			}
+			return applied, fmt.Errorf("%s: price change %s, %w", op, change.ChangeID, err)
+		}
+		if change.Status == models.PriceChangeApplied {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// recordPriceChanges writes every price of updated that differs from current to the price history. Items priced
+// per variant only record their variants.
+func (s *MenuService) recordPriceChanges(ctx context.Context, current, updated models.MenuItem) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	record := func(variantID string, oldPrice, newPrice float64) error {
+		if oldPrice == newPrice {
+			return nil
+		}
+		_, err := s.PriceChanges.CreatePriceChange(ctx, models.PriceChange{
+			ChangeID:    "PC-" + utils.GenerateRandomString(8),
+			ProductID:   updated.ProductId,
+			VariantID:   variantID,
+			OldPrice:    oldPrice,
+			NewPrice:    newPrice,
+			Status:      models.PriceChangeApplied,
+			ChangedBy:   auth.UserIDFromContext(ctx),
+			CreatedAt:   now,
+			EffectiveAt: now,
+			AppliedAt:   now,
+		})
+		return err
+	}
+
+	// the base price isn't used by items priced per variant, before or after the change
+	if len(current.Variants) == 0 && len(updated.Variants) == 0 {
+		if err := record("", current.Price, updated.Price); err != nil {
+			return err
+		}
+	}
+	for _, variant := range updated.Variants {
+		// a new variant has no price to change from
+		if oldPrice, ok := currentPrice(current, variant.VariantID); ok && variant.VariantID != "" {
+			if err := record(variant.VariantID, oldPrice, variant.Price); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// currentPrice returns the price of the item, or of its variant when variantID is given. Items with
+// variants only have a price per variant.
+func currentPrice(item models.MenuItem, variantID string) (float64, bool) {
+	if variantID == "" {
+		return item.Price, item.ProductId != "" && len(item.Variants) == 0
+	}
+	i := slices.IndexFunc(item.Variants, func(variant models.MenuItemVariant) bool { return variant.VariantID == variantID })
+	if i < 0 {
+		return 0, false
+	}
+	return item.Variants[i].Price, true
+}
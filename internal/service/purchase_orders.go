@@ -0,0 +1,260 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/internal/utils"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type PurchaseOrderRepository interface {
+	CreatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) (string, error)
+	ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter, query models.ListQuery) (models.Page[models.PurchaseOrder], error)
+	GetPurchaseOrderById(ctx context.Context, id string) (models.PurchaseOrder, error)
+	UpdatePurchaseOrderById(ctx context.Context, id, status string, po models.PurchaseOrder) error
+	DeletePurchaseOrderById(ctx context.Context, id, status string) error
+}
+
+// PurchaseOrderService runs the restocking workflow: a purchase order is drafted from a supplier's
+// catalog, sent to the supplier and then received, possibly over several deliveries.
+type PurchaseOrderService struct {
+	Repo             PurchaseOrderRepository
+	SupplierService  *SupplierService
+	InventoryService *InventoryService
+	Tx               Transactor
+}
+
+func NewPurchaseOrderService(repo PurchaseOrderRepository, supplierService *SupplierService, inventoryService *InventoryService, tx Transactor) *PurchaseOrderService {
+	return &PurchaseOrderService{repo, supplierService, inventoryService, tx}
+}
+
+func (s *PurchaseOrderService) CreatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) (string, error) {
+	const op = "service.CreatePurchaseOrder"
+
+	po, err := s.priceLines(ctx, po)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	po.PurchaseOrderID = "PO-" + utils.GenerateRandomString(8)
+	po.Status = models.PurchaseOrderDraft
+	po.CreatedBy = auth.UserIDFromContext(ctx)
+	po.CreatedAt = time.Now().Format(time.RFC3339)
+	po.SentAt = ""
+	po.ReceivedAt = ""
+
+	id, err := s.Repo.CreatePurchaseOrder(ctx, po)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+func (s *PurchaseOrderService) GetAllPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter, query models.ListQuery) (models.Page[models.PurchaseOrder], error) {
+	const op = "service.GetAllPurchaseOrders"
+	pos, err := s.Repo.ListPurchaseOrders(ctx, filter, query)
+	if err != nil {
+		return models.Page[models.PurchaseOrder]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return pos, nil
+}
+
+func (s *PurchaseOrderService) GetPurchaseOrderById(ctx context.Context, id string) (models.PurchaseOrder, error) {
+	const op = "service.GetPurchaseOrderById"
+	po, err := s.Repo.GetPurchaseOrderById(ctx, id)
+	if err != nil {
+		return models.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return po, nil
+}
+
+// UpdatePurchaseOrderById replaces the supplier and lines of a draft.
+func (s *PurchaseOrderService) UpdatePurchaseOrderById(ctx context.Context, id string, po models.PurchaseOrder) error {
+	const op = "service.UpdatePurchaseOrderById"
+
+	current, err := s.Repo.GetPurchaseOrderById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if current.Status != models.PurchaseOrderDraft {
+		return fmt.Errorf("%s: %w: only drafts can be edited, %s is %s", op, ErrIllegalTransition, id, current.Status)
+	}
+
+	po, err = s.priceLines(ctx, po)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	po.Status = current.Status
+	if err := s.Repo.UpdatePurchaseOrderById(ctx, id, current.Status, po); err != nil {
+		return fmt.Errorf("%s: %w", op, conflictAsIllegalTransition(err))
+	}
+	return nil
+}
+
+func (s *PurchaseOrderService) DeletePurchaseOrderById(ctx context.Context, id string) error {
+	const op = "service.DeletePurchaseOrderById"
+
+	current, err := s.Repo.GetPurchaseOrderById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if current.Status != models.PurchaseOrderDraft {
+		return fmt.Errorf("%s: %w: only drafts can be deleted, %s is %s", op, ErrIllegalTransition, id, current.Status)
+	}
+
+	if err := s.Repo.DeletePurchaseOrderById(ctx, id, current.Status); err != nil {
+		return fmt.Errorf("%s: %w", op, conflictAsIllegalTransition(err))
+	}
+	return nil
+}
+
+// SendPurchaseOrder marks a draft as sent to the supplier.
+func (s *PurchaseOrderService) SendPurchaseOrder(ctx context.Context, id string) (models.PurchaseOrder, error) {
+	const op = "service.SendPurchaseOrder"
+
+	po, err := s.Repo.GetPurchaseOrderById(ctx, id)
+	if err != nil {
+		return models.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if po.Status != models.PurchaseOrderDraft {
+		return models.PurchaseOrder{}, fmt.Errorf("%s: %w: %s -> %s", op, ErrIllegalTransition, po.Status, models.PurchaseOrderSent)
+	}
+
+	po.Status = models.PurchaseOrderSent
+	po.SentAt = time.Now().Format(time.RFC3339)
+	if err := s.Repo.UpdatePurchaseOrderById(ctx, id, models.PurchaseOrderDraft, po); err != nil {
+		return models.PurchaseOrder{}, fmt.Errorf("%s: %w", op, conflictAsIllegalTransition(err))
+	}
+	return po, nil
+}
+
+// ReceivePurchaseOrder books the delivered packs into the inventory and moves the purchase order
+// to partially_received or, once every line is complete, to received.
+func (s *PurchaseOrderService) ReceivePurchaseOrder(ctx context.Context, id string, payload models.ReceivePayload) (models.PurchaseOrder, error) {
+	const op = "service.ReceivePurchaseOrder"
+
+	var received models.PurchaseOrder
+	err := s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		po, err := s.Repo.GetPurchaseOrderById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if po.Status != models.PurchaseOrderSent && po.Status != models.PurchaseOrderPartiallyReceived {
+			return fmt.Errorf("%w: only sent purchase orders can be received, %s is %s", ErrIllegalTransition, id, po.Status)
+		}
+
+		delivered := payload.Lines
+		if len(delivered) == 0 {
+			for _, line := range po.Lines {
+				if left := line.Packs - line.ReceivedPacks; left > 0 {
+					delivered = append(delivered, models.PurchaseOrderLine{IngredientID: line.IngredientID, Packs: left})
+				}
+			}
+		}
+
+		from := po.Status
+		lines := slices.Clone(po.Lines)
+		stock := make(map[string]float64)
+		costs := make(map[string]float64)
+		for _, delivery := range delivered {
+			i := slices.IndexFunc(lines, func(line models.PurchaseOrderLine) bool {
+				return line.IngredientID == delivery.IngredientID
+			})
+			if i == -1 {
+				return fmt.Errorf("%w: %s is not on purchase order %s", ErrInvalidPurchaseOrder, delivery.IngredientID, id)
+			}
+			if delivery.Packs <= 0 || delivery.Packs > lines[i].Packs-lines[i].ReceivedPacks {
+				return fmt.Errorf("%w: %d packs of %s are outstanding", ErrInvalidPurchaseOrder, lines[i].Packs-lines[i].ReceivedPacks, delivery.IngredientID)
+			}
+			lines[i].ReceivedPacks += delivery.Packs
+			stock[delivery.IngredientID] += float64(delivery.Packs) * lines[i].PackSize
+			costs[delivery.IngredientID] = lines[i].UnitCost / lines[i].PackSize
+		}
+
+		po.Lines = lines
+		po.Status = models.PurchaseOrderReceived
+		for _, line := range lines {
+			if line.ReceivedPacks < line.Packs {
+				po.Status = models.PurchaseOrderPartiallyReceived
+			}
+		}
+		po.ReceivedAt = time.Now().Format(time.RFC3339)
+		if err := s.Repo.UpdatePurchaseOrderById(ctx, id, from, po); err != nil {
+			return conflictAsIllegalTransition(err)
+		}
+
+		for ingredientID, qty := range stock {
+			movement := models.InventoryMovement{Reason: models.MovementRestock, PurchaseOrderID: id, UnitCost: costs[ingredientID]}
+			if err := s.InventoryService.AddStock(ctx, ingredientID, qty, movement); err != nil {
+				return fmt.Errorf("failed to add stock for ingredient: %s, %w", ingredientID, err)
+			}
+		}
+		received = po
+		return nil
+	})
+	if err != nil {
+		return models.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return received, nil
+}
+
+// priceLines checks the lines against the supplier's catalog, copies pack size and cost onto them
+// and computes the total.
+func (s *PurchaseOrderService) priceLines(ctx context.Context, po models.PurchaseOrder) (models.PurchaseOrder, error) {
+	supplier, err := s.SupplierService.GetSupplierById(ctx, po.SupplierID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: unknown supplier %q", ErrInvalidPurchaseOrder, po.SupplierID)
+		}
+		return models.PurchaseOrder{}, err
+	}
+	if len(po.Lines) == 0 {
+		return models.PurchaseOrder{}, fmt.Errorf("%w: purchase order must have at least one line", ErrInvalidPurchaseOrder)
+	}
+
+	lines := make([]models.PurchaseOrderLine, len(po.Lines))
+	var total float64
+	for i, line := range po.Lines {
+		if line.Packs <= 0 {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: packs of %s must be greater than zero", ErrInvalidPurchaseOrder, line.IngredientID)
+		}
+		if slices.ContainsFunc(po.Lines[:i], func(other models.PurchaseOrderLine) bool { return other.IngredientID == line.IngredientID }) {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: %s is on more than one line", ErrInvalidPurchaseOrder, line.IngredientID)
+		}
+		j := slices.IndexFunc(supplier.Products, func(product models.SupplierProduct) bool {
+			return product.IngredientID == line.IngredientID
+		})
+		if j == -1 {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: %s doesn't sell %s", ErrInvalidPurchaseOrder, supplier.Name, line.IngredientID)
+		}
+		if _, err := s.InventoryService.GetInventoryItemById(ctx, line.IngredientID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return models.PurchaseOrder{}, fmt.Errorf("%w: %s is not an inventory item", ErrInvalidPurchaseOrder, line.IngredientID)
+			}
+			return models.PurchaseOrder{}, err
+		}
+		product := supplier.Products[j]
+		lines[i] = models.PurchaseOrderLine{
+			IngredientID: line.IngredientID,
+			Packs:        line.Packs,
+			PackSize:     product.PackSize,
+			UnitCost:     product.UnitCost,
+		}
+		total += product.UnitCost * float64(line.Packs)
+	}
+
+	po.Lines = lines
+	po.Total = total
+	return po, nil
+}
+
+func conflictAsIllegalTransition(err error) error {
+	if errors.Is(err, repository.ErrConflict) {
+		return fmt.Errorf("%w: purchase order was changed concurrently", ErrIllegalTransition)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/auth"
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// RefundOrder refunds the requested items of a picked up order, or everything that was not
+// refunded yet when no items are given. The ingredients deducted for the refunded items are put
+// back into the inventory, and once every item is refunded the order moves to refunded.
+func (s *OrderService) RefundOrder(ctx context.Context, orderId string, payload models.RefundPayload) (models.Order, error) {
+	const op = "service.RefundOrder"
+
+	roles := orderTransitions[models.OrderStatusPickedUp][models.OrderStatusRefunded]
+	if !slices.Contains(roles, auth.RoleFromContext(ctx)) {
+		return models.Order{}, fmt.Errorf("%s: %w: refund", op, ErrTransitionForbidden)
+	}
+
+	var refunded models.Order
+	err := s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+		// read the order inside the transaction, so concurrent refunds can't both refund the same items
+		order, err := s.OrderRepo.GetOrderById(ctx, orderId)
+		if err != nil {
+			return fmt.Errorf("order not found: %s, %w", orderId, err)
+		}
+		if order.Status != models.OrderStatusPickedUp {
+			return fmt.Errorf("%w: only picked up orders can be refunded, %s is %s", ErrIllegalTransition, orderId, order.Status)
+		}
+
+		requested := payload.Items
+		if len(requested) == 0 {
+			for _, item := range order.Items {
+				if left := item.Quantity - item.RefundedQuantity; left > 0 {
+					requested = append(requested, models.OrderItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: left})
+				}
+			}
+			if len(requested) == 0 {
+				return fmt.Errorf("%w: nothing left to refund", ErrInvalidRefund)
+			}
+		}
+
+		items := slices.Clone(order.Items)
+		restore := make(map[string]float64)
+		var amount float64
+		for _, line := range requested {
+			if line.Quantity <= 0 {
+				return fmt.Errorf("%w: quantity for %s must be greater than zero", ErrInvalidRefund, line.ProductID)
+			}
+			left := line.Quantity
+			for i := range items {
+				// a refund line without a variant refunds the product in whatever variants were ordered
+				if items[i].ProductID != line.ProductID || (line.VariantID != "" && items[i].VariantID != line.VariantID) || left == 0 {
+					continue
+				}
+				n := min(left, items[i].Quantity-items[i].RefundedQuantity)
+				if n <= 0 {
+					continue
+				}
+				items[i].RefundedQuantity += n
+				amount += items[i].UnitPrice * float64(n)
+				for _, ingredient := range items[i].Ingredients {
+					restore[ingredient.IngredientID] += ingredient.Quantity * float64(n)
+				}
+				left -= n
+			}
+			if left > 0 {
+				return fmt.Errorf("%w: only %d of %s left to refund", ErrInvalidRefund, line.Quantity-left, line.ProductID)
+			}
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		refund := models.Refund{
+			Items:      requested,
+			Amount:     amount,
+			Reason:     payload.Reason,
+			RefundedBy: auth.UserIDFromContext(ctx),
+			RefundedAt: now,
+		}
+		if err := s.OrderRepo.AddOrderRefund(ctx, orderId, items, refund); err != nil {
+			return fmt.Errorf("failed to record refund, %w", err)
+		}
+		order.Items = items
+		order.Refunds = append(order.Refunds, refund)
+		order.RefundedAmount += amount
+
+		if fullyRefunded(items) {
+			change := models.StatusChange{
+				From:      order.Status,
+				Status:    models.OrderStatusRefunded,
+				ChangedBy: refund.RefundedBy,
+				ChangedAt: now,
+			}
+			if err := s.OrderRepo.UpdateOrderStatus(ctx, orderId, order.Status, change); err != nil {
+				return fmt.Errorf("failed to update order status, %w", err)
+			}
+			order.Status = change.Status
+			order.StatusHistory = append(order.StatusHistory, change)
+		}
+
+		if err := applyStock(ctx, restore, recorded(s.InventoryService.AddStock, models.MovementRefundRestore, orderId)); err != nil {
+			return fmt.Errorf("failed to restore stock, %w", err)
+		}
+		refunded = order
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if refunded.Status == models.OrderStatusRefunded {
+		s.publish(ctx, models.OrderEventStatusChanged, refunded)
+	}
+	return refunded, nil
+}
+
+func fullyRefunded(items []models.OrderItem) bool {
+	for _, item := range items {
+		if item.RefundedQuantity < item.Quantity {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"cofee-shop-mongo/internal/repository"
+	"cofee-shop-mongo/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// reserveOrder attaches the recipes to the order's items and marks their ingredients as reserved
+// until the reservation TTL runs out. The stock itself is reserved by the caller.
+func (s *OrderService) reserveOrder(ctx context.Context, order models.Order) (models.Order, error) {
+	items, err := s.attachRecipes(ctx, order.Items)
+	if err != nil {
+		return models.Order{}, err
+	}
+	order.Items = items
+	order.Stock = models.StockReserved
+	order.ReservedUntil = time.Now().Add(s.ReservationTTL).UTC().Format(time.RFC3339)
+	return order, nil
+}
+
+// ReleaseExpiredReservations gives the stock held by orders whose reservation ran out back to the
+// inventory and returns how many orders it released. Those orders stay open, and their ingredients
+// are deducted as usual when they are picked up.
+func (s *OrderService) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	const op = "service.ReleaseExpiredReservations"
+
+	orders, err := s.OrderRepo.GetOrdersWithExpiredReservations(ctx, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	released := 0
+	for _, order := range orders {
+		err := s.Tx.WithTransaction(ctx, func(ctx context.Context) error {
+			if err := s.OrderRepo.UpdateOrderStock(ctx, order.ProductId, models.StockReserved, models.StockReleased); err != nil {
+				return err
+			}
+			return applyStock(ctx, outstandingIngredients(order.Items), s.InventoryService.ReleaseStock)
+		})
+		if err != nil {
+			// the order was picked up or cancelled in the meantime
+			if errors.Is(err, repository.ErrConflict) {
+				continue
+			}
+			return released, fmt.Errorf("%s: order %s, %w", op, order.ProductId, err)
+		}
+		released++
+	}
+
+	return released, nil
+}
+
+// applyStock calls apply for every ingredient in amounts.
+func applyStock(ctx context.Context, amounts map[string]float64, apply func(ctx context.Context, ingredientID string, qty float64) error) error {
+	for ingredientID, qty := range amounts {
+		if err := apply(ctx, ingredientID, qty); err != nil {
+			return fmt.Errorf("ingredient %s, %w", ingredientID, err)
+		}
+	}
+	return nil
+}
+
+// recorded binds the ledger reason and order of a change of the quantity on hand, so it fits applyStock.
+func recorded(change func(ctx context.Context, ingredientID string, qty float64, movement models.InventoryMovement) error, reason, orderId string) func(ctx context.Context, ingredientID string, qty float64) error {
+	return func(ctx context.Context, ingredientID string, qty float64) error {
+		return change(ctx, ingredientID, qty, models.InventoryMovement{Reason: reason, OrderID: orderId})
+	}
+}
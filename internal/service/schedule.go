@@ -0,0 +1,55 @@
+package service
+
+import (
+	"cofee-shop-mongo/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// onSale reports whether an item with the schedule can be ordered at t, read in the shop's timezone loc.
+func onSale(schedule *models.Schedule, t time.Time, loc *time.Location) bool {
+	if schedule == nil {
+		return true
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	if len(schedule.Windows) == 0 {
+		return scheduledOn(schedule, local)
+	}
+
+	clock := local.Format("15:04")
+	for _, window := range schedule.Windows {
+		if window.From <= window.To {
+			if clock >= window.From && clock < window.To && scheduledOn(schedule, local) {
+				return true
+			}
+			continue
+		}
+		// the part of an overnight window after midnight belongs to the day it started on
+		if clock >= window.From && scheduledOn(schedule, local) {
+			return true
+		}
+		if clock < window.To && scheduledOn(schedule, local.AddDate(0, 0, -1)) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduledOn reports whether the day of local is one of the schedule's days and within its dates.
+func scheduledOn(schedule *models.Schedule, local time.Time) bool {
+	date := local.Format(time.DateOnly)
+	if schedule.StartDate != "" && date < schedule.StartDate {
+		return false
+	}
+	if schedule.EndDate != "" && date > schedule.EndDate {
+		return false
+	}
+	if len(schedule.Days) == 0 {
+		return true
+	}
+	return slices.Contains(schedule.Days, strings.ToLower(local.Weekday().String()[:3]))
+}
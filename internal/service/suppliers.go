@@ -0,0 +1,69 @@
+package service
+
+import (
+	"cofee-shop-mongo/models"
+	"context"
+	"fmt"
+)
+
+type SupplierRepository interface {
+	CreateSupplier(ctx context.Context, supplier models.Supplier) (string, error)
+	ListSuppliers(ctx context.Context, filter models.SupplierFilter, query models.ListQuery) (models.Page[models.Supplier], error)
+	GetSupplierById(ctx context.Context, id string) (models.Supplier, error)
+	UpdateSupplierById(ctx context.Context, id string, supplier models.Supplier) error
+	DeleteSupplierById(ctx context.Context, id string) error
+}
+
+type SupplierService struct {
+	Repo SupplierRepository
+}
+
+func NewSupplierService(repo SupplierRepository) *SupplierService {
+	return &SupplierService{Repo: repo}
+}
+
+func (s *SupplierService) CreateSupplier(ctx context.Context, supplier models.Supplier) (string, error) {
+	const op = "service.CreateSupplier"
+	id, err := s.Repo.CreateSupplier(ctx, supplier)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+func (s *SupplierService) GetAllSuppliers(ctx context.Context, filter models.SupplierFilter, query models.ListQuery) (models.Page[models.Supplier], error) {
+	const op = "service.GetAllSuppliers"
+	suppliers, err := s.Repo.ListSuppliers(ctx, filter, query)
+	if err != nil {
+		return models.Page[models.Supplier]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return suppliers, nil
+}
+
+func (s *SupplierService) GetSupplierById(ctx context.Context, id string) (models.Supplier, error) {
+	const op = "service.GetSupplierById"
+	supplier, err := s.Repo.GetSupplierById(ctx, id)
+	if err != nil {
+		return models.Supplier{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return supplier, nil
+}
+
+func (s *SupplierService) UpdateSupplierById(ctx context.Context, id string, supplier models.Supplier) error {
+	const op = "service.UpdateSupplierById"
+	supplier.SupplierID = id
+	err := s.Repo.UpdateSupplierById(ctx, id, supplier)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *SupplierService) DeleteSupplierById(ctx context.Context, id string) error {
+	const op = "service.DeleteSupplierById"
+	err := s.Repo.DeleteSupplierById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package service
+
+import "context"
+
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -8,7 +8,7 @@ import (
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, user models.User) (string, error)
-	GetAllUsers(ctx context.Context) ([]models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter, query models.ListQuery) (models.Page[models.User], error)
 	GetUserById(ctx context.Context, userId string) (models.User, error)
 	UpdateUserById(ctx context.Context, userId string, user models.User) error
 	DeleteUserById(ctx context.Context, userId string) error
@@ -33,12 +33,12 @@ func (s *UserService) CreateUser(ctx context.Context, user models.User) (string,
 	return id, nil
 }
 
-func (s *UserService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+func (s *UserService) GetAllUsers(ctx context.Context, filter models.UserFilter, query models.ListQuery) (models.Page[models.User], error) {
 	const op = "service.GetAllUsers"
 
-	users, err := s.Repo.GetAllUsers(ctx)
+	users, err := s.Repo.ListUsers(ctx, filter, query)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.Page[models.User]{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return users, nil
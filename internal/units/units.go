@@ -0,0 +1,106 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownUnit       = errors.New("unknown unit")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+)
+
+// Dimension is what a unit measures. Quantities can only be converted between units of the same dimension.
+type Dimension string
+
+const (
+	Mass   Dimension = "mass"
+	Volume Dimension = "volume"
+	Shots  Dimension = "shots"
+	Pieces Dimension = "pieces"
+)
+
+// Unit is a unit of measure together with how many of its dimension's base unit
+// (grams, millilitres, shots or pieces) one of it is.
+type Unit struct {
+	Name      string
+	Dimension Dimension
+	Factor    float64
+}
+
+var known = map[string]Unit{
+	"g":      {"g", Mass, 1},
+	"kg":     {"kg", Mass, 1000},
+	"oz":     {"oz", Mass, 28.349523125},
+	"lb":     {"lb", Mass, 453.59237},
+	"ml":     {"ml", Volume, 1},
+	"l":      {"l", Volume, 1000},
+	"shots":  {"shots", Shots, 1},
+	"pieces": {"pieces", Pieces, 1},
+}
+
+var aliases = map[string]string{
+	"gram":        "g",
+	"grams":       "g",
+	"kilogram":    "kg",
+	"kilograms":   "kg",
+	"ounce":       "oz",
+	"ounces":      "oz",
+	"lbs":         "lb",
+	"pound":       "lb",
+	"pounds":      "lb",
+	"milliliter":  "ml",
+	"milliliters": "ml",
+	"millilitre":  "ml",
+	"millilitres": "ml",
+	"liter":       "l",
+	"liters":      "l",
+	"litre":       "l",
+	"litres":      "l",
+	"shot":        "shots",
+	"piece":       "pieces",
+	"pcs":         "pieces",
+	"pc":          "pieces",
+}
+
+// Parse looks up a unit by its name or one of its common spellings, ignoring case.
+func Parse(name string) (Unit, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := aliases[key]; ok {
+		key = alias
+	}
+	unit, ok := known[key]
+	if !ok {
+		return Unit{}, fmt.Errorf("%w %q", ErrUnknownUnit, name)
+	}
+	return unit, nil
+}
+
+// Normalize returns the canonical name of the unit, e.g. "kg" for "Kilograms".
+func Normalize(name string) (string, error) {
+	unit, err := Parse(name)
+	if err != nil {
+		return "", err
+	}
+	return unit.Name, nil
+}
+
+// Convert converts qty from one unit to another of the same dimension.
+func Convert(qty float64, from, to string) (float64, error) {
+	fromUnit, err := Parse(from)
+	if err != nil {
+		return 0, err
+	}
+	toUnit, err := Parse(to)
+	if err != nil {
+		return 0, err
+	}
+	if fromUnit.Dimension != toUnit.Dimension {
+		return 0, fmt.Errorf("%w: %s (%s) and %s (%s)", ErrIncompatibleUnits, fromUnit.Name, fromUnit.Dimension, toUnit.Name, toUnit.Dimension)
+	}
+	if fromUnit.Name == toUnit.Name {
+		return qty, nil
+	}
+	return qty * fromUnit.Factor / toUnit.Factor, nil
+}
@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"cofee-shop-mongo/models"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -25,6 +28,82 @@ func WriteError(w http.ResponseWriter, status int, err error) {
 	WriteJSON(w, status, map[string]string{"error": err.Error()})
 }
 
+// WriteErrorCode is WriteError with an additional machine-readable error code.
+func WriteErrorCode(w http.ResponseWriter, status int, code string, err error) {
+	WriteJSON(w, status, map[string]string{"error": err.Error(), "code": code})
+}
+
+// ParseDateRange reads the optional "from" and "to" query parameters, given either as RFC3339
+// timestamps or as 2006-01-02 dates, and returns them as UTC RFC3339 bounds: from inclusive,
+// to exclusive. A date in "to" includes that whole day.
+func ParseDateRange(r *http.Request) (from, to string, err error) {
+	if value := r.URL.Query().Get("from"); value != "" {
+		t, _, err := parseTimeOrDate(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid from: %w", err)
+		}
+		from = t.UTC().Format(time.RFC3339)
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		t, isDate, err := parseTimeOrDate(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid to: %w", err)
+		}
+		if isDate {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t.UTC().Format(time.RFC3339)
+	}
+	return from, to, nil
+}
+
+// Bounds of the "limit" query parameter of list endpoints.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 100
+)
+
+// ParseListQuery reads the "limit", "sort" and "cursor" query parameters of a list endpoint.
+func ParseListQuery(r *http.Request) (models.ListQuery, error) {
+	query := models.ListQuery{
+		Limit:  DefaultListLimit,
+		Sort:   r.URL.Query().Get("sort"),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxListLimit {
+			return models.ListQuery{}, fmt.Errorf("limit must be a number from 1 to %d", MaxListLimit)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+// ParseIncludeArchived reads the optional "include_archived" query parameter, false when it is missing.
+func ParseIncludeArchived(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("include_archived")
+	if value == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("include_archived must be true or false")
+	}
+	return include, nil
+}
+
+func parseTimeOrDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%q is neither an RFC3339 timestamp nor a date", value)
+	}
+	return t, true, nil
+}
+
 const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
 // GenerateRandomString generates a random string of given length
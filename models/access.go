@@ -1,6 +1,10 @@
 package models
 
+// RoleClient is the role of the customers who sign up themselves.
+const RoleClient = "client"
+
 var (
-	AdminAccess = []string{"admin"}
-	StaffAccess = []string{"admin", "staff"}
+	AdminAccess  = []string{"admin"}
+	StaffAccess  = []string{"admin", "staff"}
+	ClientAccess = []string{"admin", "staff", "client"}
 )
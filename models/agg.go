@@ -4,3 +4,10 @@ type PopularItem struct {
 	ProductId string `json:"product_id" bson:"_id"`
 	Sold      int    `json:"total_quantity" bson:"total_quantity"`
 }
+
+// ItemSales is what a menu item sold, with bundles attributed to the items they are made of.
+type ItemSales struct {
+	ProductId string  `json:"product_id" bson:"_id"`
+	Quantity  int     `json:"quantity" bson:"quantity"`
+	Revenue   float64 `json:"revenue" bson:"revenue"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// MenuItemCost is what the ingredients of one serving of a menu item, or one of its variants, cost
+// and what is left of the price after paying for them.
+type MenuItemCost struct {
+	ProductId      string           `json:"product_id"`
+	VariantID      string           `json:"variant_id,omitempty"`
+	Name           string           `json:"name"`
+	Price          float64          `json:"price"`
+	IngredientCost float64          `json:"ingredient_cost"`
+	GrossMargin    float64          `json:"gross_margin"`
+	MarginPercent  float64          `json:"margin_percent"`
+	Ingredients    []IngredientCost `json:"ingredients"`
+	// MissingCosts lists the ingredients whose cost is unknown, so IngredientCost is too low.
+	MissingCosts []string `json:"missing_costs,omitempty"`
+}
+
+// IngredientCost is the cost of one ingredient of a recipe, Quantity is in the unit it is stocked in.
+type IngredientCost struct {
+	IngredientID string  `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	UnitCost     float64 `json:"unit_cost"`
+	Cost         float64 `json:"cost"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// Types of order events.
+const (
+	OrderEventCreated       = "order.created"
+	OrderEventStatusChanged = "order.status_changed"
+)
+
+// OrderEvent tells the order board and customers waiting for their order that an order was placed or moved
+// to another status. ID is what SSE clients send back in Last-Event-ID when they reconnect.
+type OrderEvent struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	OrderID      string `json:"order_id"`
+	PickupNumber string `json:"pickup_number,omitempty"`
+	Status       string `json:"status"`
+	At           string `json:"at"`
+	Order        Order  `json:"order"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the outcome of the first request made with an Idempotency-Key. It is pending while
+// that request is handled, and once it completes retries of the request get the stored response back.
+type IdempotencyRecord struct {
+	Key string `bson:"_id"`
+	// Fingerprint is the hash of the body of the first request, a retry has to send the same body.
+	Fingerprint string    `bson:"fingerprint"`
+	Completed   bool      `bson:"completed"`
+	Status      int       `bson:"status,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
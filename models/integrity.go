@@ -0,0 +1,48 @@
+package models
+
+// ReferenceCheck describes a reference between two collections: Field of every document in Collection
+// must match TargetField of a document in Target. Field is a dotted path that may go through arrays,
+// e.g. "variants.ingredients.ingredient_id".
+type ReferenceCheck struct {
+	Collection  string
+	IDField     string
+	Field       string
+	Target      string
+	TargetField string
+}
+
+// DanglingReference is a reference to a document that doesn't exist, found by the integrity check RunID.
+// Seq numbers the references of a check in the order they were found.
+type DanglingReference struct {
+	RunID      string `bson:"run_id" json:"-"`
+	Seq        int    `bson:"seq" json:"-"`
+	Collection string `bson:"collection" json:"collection"`
+	DocumentID string `bson:"document_id" json:"document_id"`
+	Field      string `bson:"field" json:"field"`
+	Reference  string `bson:"reference" json:"reference"`
+}
+
+const (
+	IntegrityCheckRunning = "running"
+	IntegrityCheckDone    = "done"
+	IntegrityCheckFailed  = "failed"
+)
+
+// IntegrityRun is one scan of the collections for dangling references. Scans run in the background,
+// DanglingCount is how many references the scan has found so far.
+type IntegrityRun struct {
+	RunID      string `bson:"run_id" json:"run_id"`
+	Status     string `bson:"status" json:"status"`
+	StartedAt  string `bson:"started_at" json:"started_at"`
+	FinishedAt string `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	// Checks is how many kinds of references are checked.
+	Checks        int    `bson:"checks" json:"checks"`
+	DanglingCount int    `bson:"dangling_count" json:"dangling_count"`
+	Error         string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// IntegrityReport is an integrity check with one page of the dangling references it found.
+type IntegrityReport struct {
+	IntegrityRun
+	DanglingReferences Page[DanglingReference] `json:"dangling_references"`
+}
@@ -1,8 +1,75 @@
 package models
 
 type InventoryItem struct {
-	IngredientID string  `bson:"ingredient_id" json:"ingredient_id"`
-	Name         string  `bson:"name" json:"name"`
-	Quantity     float64 `bson:"quantity" json:"quantity"`
-	Unit         string  `bson:"unit" json:"unit"`
+	IngredientID string `bson:"ingredient_id" json:"ingredient_id"`
+	Name         string `bson:"name" json:"name"`
+	// Quantity is the amount on hand, Reserved the part of it held for orders that were not picked up yet.
+	Quantity float64 `bson:"quantity" json:"quantity"`
+	Reserved float64 `bson:"reserved" json:"reserved"`
+	Unit     string  `bson:"unit" json:"unit"`
+	// The item is low on stock once Quantity drops to ReorderPoint, ReorderQuantity is how much
+	// to order then. A zero ReorderPoint disables the alerts.
+	ReorderPoint    float64 `bson:"reorder_point" json:"reorder_point"`
+	ReorderQuantity float64 `bson:"reorder_quantity" json:"reorder_quantity"`
+	// UnitCost is what one unit of the item costs, the weighted average of what was paid for the stock.
+	UnitCost float64 `bson:"unit_cost" json:"unit_cost"`
+	// Allergens are tags like "dairy" or "nuts", Nutrition the facts of one unit of the item.
+	Allergens []string  `bson:"allergens,omitempty" json:"allergens,omitempty"`
+	Nutrition Nutrition `bson:"nutrition" json:"nutrition"`
+	// ArchivedAt is set once the item is deleted, it is kept for the history of its movements.
+	ArchivedAt string `bson:"archived_at,omitempty" json:"archived_at,omitempty"`
+}
+
+// Nutrition facts of one unit of an inventory item, or of one serving of a menu item.
+type Nutrition struct {
+	Kcal     float64 `bson:"kcal" json:"kcal"`
+	Sugar    float64 `bson:"sugar_g" json:"sugar_g"`
+	Caffeine float64 `bson:"caffeine_mg" json:"caffeine_mg"`
+}
+
+// LowStockAlert is sent when a deduction brings an item down to its reorder point.
+type LowStockAlert struct {
+	IngredientID    string  `json:"ingredient_id"`
+	Name            string  `json:"name"`
+	Quantity        float64 `json:"quantity"`
+	Unit            string  `json:"unit"`
+	ReorderPoint    float64 `json:"reorder_point"`
+	ReorderQuantity float64 `json:"reorder_quantity"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+// Reasons for an inventory movement.
+const (
+	MovementInitialStock     = "initial_stock"
+	MovementOrderDeduction   = "order_deduction"
+	MovementRefundRestore    = "refund_restore"
+	MovementManualAdjustment = "manual_adjustment"
+	MovementRestock          = "restock"
+	MovementWaste            = "waste"
+	MovementStocktake        = "stocktake"
+)
+
+// ManualMovementReasons are the reasons staff may give when editing an inventory item by hand.
+var ManualMovementReasons = []string{MovementManualAdjustment, MovementRestock, MovementWaste, MovementStocktake}
+
+// InventoryMovement is one entry of the append-only ledger of changes to an item's quantity on hand.
+type InventoryMovement struct {
+	IngredientID    string  `bson:"ingredient_id" json:"ingredient_id"`
+	Delta           float64 `bson:"delta" json:"delta"`
+	Balance         float64 `bson:"balance" json:"balance"`
+	Reason          string  `bson:"reason" json:"reason"`
+	OrderID         string  `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	PurchaseOrderID string  `bson:"purchase_order_id,omitempty" json:"purchase_order_id,omitempty"`
+	// UnitCost is what was paid per unit for restocked items.
+	UnitCost  float64 `bson:"unit_cost,omitempty" json:"unit_cost,omitempty"`
+	UserID    string  `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Note      string  `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt string  `bson:"created_at" json:"created_at"`
+}
+
+// InventoryUpdatePayload is an inventory item together with why it was changed.
+type InventoryUpdatePayload struct {
+	InventoryItem
+	Reason string `json:"reason"`
+	Note   string `json:"note"`
 }
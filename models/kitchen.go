@@ -0,0 +1,41 @@
+package models
+
+// States of a kitchen ticket.
+const (
+	TicketQueued     = "queued"
+	TicketInProgress = "in_progress"
+	TicketDone       = "done"
+)
+
+// DefaultStation prepares the items whose menu item doesn't name a station.
+const DefaultStation = "counter"
+
+// KitchenTicket is the part of an accepted order one station prepares. The order is ready once all its
+// tickets are done.
+type KitchenTicket struct {
+	TicketID     string       `bson:"ticket_id" json:"ticket_id"`
+	OrderID      string       `bson:"order_id" json:"order_id"`
+	PickupNumber string       `bson:"pickup_number,omitempty" json:"pickup_number,omitempty"`
+	Station      string       `bson:"station" json:"station"`
+	Items        []TicketItem `bson:"items" json:"items"`
+	Status       string       `bson:"status" json:"status"`
+	CreatedAt    string       `bson:"created_at" json:"created_at"`
+	StartedAt    string       `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	DoneAt       string       `bson:"done_at,omitempty" json:"done_at,omitempty"`
+}
+
+// TicketItem is one line of a ticket. Bundle names the bundle the item was ordered in, if any.
+type TicketItem struct {
+	ProductID string   `bson:"product_id" json:"product_id"`
+	VariantID string   `bson:"variant_id,omitempty" json:"variant_id,omitempty"`
+	Name      string   `bson:"name" json:"name"`
+	Quantity  int      `bson:"quantity" json:"quantity"`
+	Modifiers []string `bson:"modifiers,omitempty" json:"modifiers,omitempty"`
+	Bundle    string   `bson:"bundle,omitempty" json:"bundle,omitempty"`
+}
+
+// TicketFilter narrows down the tickets of the kitchen display, without Statuses the open ones are listed.
+type TicketFilter struct {
+	Station  string
+	Statuses []string
+}
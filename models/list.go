@@ -0,0 +1,43 @@
+package models
+
+// ListQuery asks a list endpoint for one page: at most Limit results in the order of Sort, starting
+// after the position Cursor points at. Sort is a field name, with a leading "-" for descending order,
+// an empty Sort is the resource's default order.
+type ListQuery struct {
+	Limit  int
+	Sort   string
+	Cursor string
+}
+
+// Page is one page of a list, NextCursor points past its last item and is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type OrderFilter struct {
+	Status       string
+	CustomerName string
+	CustomerID   string
+	// From and To bound created_at as UTC RFC3339 timestamps, From inclusive and To exclusive.
+	From string
+	To   string
+}
+
+type InventoryFilter struct {
+	NamePrefix      string
+	IncludeArchived bool
+}
+
+type UserFilter struct {
+	Role string
+}
+
+type SupplierFilter struct {
+	NamePrefix string
+}
+
+type PurchaseOrderFilter struct {
+	Status     string
+	SupplierID string
+}
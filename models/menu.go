@@ -1,14 +1,130 @@
 package models
 
+import "time"
+
+// MenuItem is something that can be ordered. An item either has a single price and recipe,
+// comes in Variants (e.g. sizes) with a price and recipe each, or is a bundle of other menu
+// items, listed in Components, sold together for Price.
 type MenuItem struct {
 	ProductId   string               `bson:"product_id" json:"product_id"`
 	Name        string               `bson:"name" json:"name"`
 	Description string               `bson:"description" json:"description"`
+	CategoryID  string               `bson:"category_id,omitempty" json:"category_id,omitempty"`
+	Price       float64              `bson:"price" json:"price"`
+	Ingredients []MenuItemIngredient `bson:"ingredients" json:"ingredients"`
+	Variants    []MenuItemVariant    `bson:"variants,omitempty" json:"variants,omitempty"`
+	Components  []BundleSlot         `bson:"components,omitempty" json:"components,omitempty"`
+	// Schedule limits when the item is on sale, without one it always is.
+	Schedule *Schedule `bson:"schedule,omitempty" json:"schedule,omitempty"`
+	// ModifierGroups are the customizations the item can be ordered with, e.g. the kind of milk.
+	ModifierGroups []ModifierGroup `bson:"modifier_groups,omitempty" json:"modifier_groups,omitempty"`
+	// Station is where the item is prepared, e.g. "espresso" or "food", items without one go to the counter.
+	// Bundles are prepared at the stations of their components.
+	Station string `bson:"station,omitempty" json:"station,omitempty"`
+	// Available and MaxServings are worked out from the current stock whenever the item is read,
+	// Allergens and Nutrition from the ingredients of its recipe.
+	Available   bool       `bson:"-" json:"available"`
+	MaxServings int        `bson:"-" json:"max_servings"`
+	Allergens   []string   `bson:"-" json:"allergens"`
+	Nutrition   *Nutrition `bson:"-" json:"nutrition,omitempty"`
+	// OnSale tells whether the schedule allows ordering the item at the time the menu was read for.
+	OnSale bool `bson:"-" json:"on_sale"`
+	// ArchivedAt is set once the item is deleted, archived items are kept for the orders that reference them.
+	ArchivedAt string `bson:"archived_at,omitempty" json:"archived_at,omitempty"`
+}
+
+// Schedule is when a menu item is on sale, in shop time. Every part that is set has to match:
+// Days are "mon" to "sun", Windows are "HH:MM" ranges with an exclusive end, and StartDate and EndDate
+// are inclusive "YYYY-MM-DD" dates.
+type Schedule struct {
+	Days      []string     `bson:"days,omitempty" json:"days,omitempty"`
+	Windows   []TimeWindow `bson:"windows,omitempty" json:"windows,omitempty"`
+	StartDate string       `bson:"start_date,omitempty" json:"start_date,omitempty"`
+	EndDate   string       `bson:"end_date,omitempty" json:"end_date,omitempty"`
+}
+
+// TimeWindow is a time of day range, a window ending before it starts runs past midnight.
+type TimeWindow struct {
+	From string `bson:"from" json:"from"`
+	To   string `bson:"to" json:"to"`
+}
+
+// MenuItemVariant is one way a menu item can be ordered, e.g. a large latte.
+type MenuItemVariant struct {
+	VariantID   string               `bson:"variant_id" json:"variant_id"`
+	Name        string               `bson:"name" json:"name"`
 	Price       float64              `bson:"price" json:"price"`
 	Ingredients []MenuItemIngredient `bson:"ingredients" json:"ingredients"`
+	Available   bool                 `bson:"-" json:"available"`
+	MaxServings int                  `bson:"-" json:"max_servings"`
+	Allergens   []string             `bson:"-" json:"allergens"`
+	Nutrition   *Nutrition           `bson:"-" json:"nutrition,omitempty"`
+}
+
+// MenuFilter narrows down the menu, zero values don't filter.
+type MenuFilter struct {
+	CategoryID string
+	// MinPrice and MaxPrice bound the price of the item or of any of its variants, zero leaves them open.
+	MinPrice         float64
+	MaxPrice         float64
+	OnlyAvailable    bool
+	ExcludeAllergens []string
+	// At is the time to show the menu for, items not on sale then are left out. The zero time shows every item.
+	At              time.Time
+	IncludeArchived bool
 }
 
+// Category groups menu items, categories are listed by ascending Position.
+type Category struct {
+	CategoryID string `bson:"category_id" json:"category_id"`
+	Name       string `bson:"name" json:"name"`
+	Position   int    `bson:"position" json:"position"`
+}
+
+// MenuItemIngredient is how much of an ingredient goes into one serving. Unit is optional,
+// without one Quantity is in the unit the ingredient is stocked in.
 type MenuItemIngredient struct {
 	IngredientID string  `bson:"ingredient_id" json:"ingredient_id"`
 	Quantity     float64 `bson:"quantity" json:"quantity"`
+	Unit         string  `bson:"unit,omitempty" json:"unit,omitempty"`
+}
+
+// ModifierGroup is a set of modifiers to choose from. A required group needs at least one modifier picked,
+// and no more than MaxSelections may be picked from it, zero meaning any number.
+type ModifierGroup struct {
+	GroupID       string     `bson:"group_id" json:"group_id"`
+	Name          string     `bson:"name" json:"name"`
+	Required      bool       `bson:"required" json:"required"`
+	MaxSelections int        `bson:"max_selections" json:"max_selections"`
+	Modifiers     []Modifier `bson:"modifiers" json:"modifiers"`
+}
+
+// Modifier changes the price of an item by PriceDelta and its recipe by Ingredients.
+type Modifier struct {
+	ModifierID  string               `bson:"modifier_id" json:"modifier_id"`
+	Name        string               `bson:"name" json:"name"`
+	PriceDelta  float64              `bson:"price_delta" json:"price_delta"`
+	Ingredients []ModifierIngredient `bson:"ingredients,omitempty" json:"ingredients,omitempty"`
+	// Allergens are the allergens the ingredients added by the modifier bring in.
+	Allergens []string `bson:"-" json:"allergens,omitempty"`
+}
+
+// ModifierIngredient adds an ingredient to the recipe, or substitutes the ingredient named by Replaces.
+// A substitute without a quantity takes over the quantity of the replaced ingredient, and a
+// substitute without an ingredient removes the replaced one, e.g. "no sugar".
+type ModifierIngredient struct {
+	IngredientID string  `bson:"ingredient_id,omitempty" json:"ingredient_id,omitempty"`
+	Quantity     float64 `bson:"quantity,omitempty" json:"quantity,omitempty"`
+	Unit         string  `bson:"unit,omitempty" json:"unit,omitempty"`
+	Replaces     string  `bson:"replaces,omitempty" json:"replaces,omitempty"`
+}
+
+// BundleSlot is one part of a bundle. The customer picks one of ProductIDs for it, a slot with a single
+// product is fixed. When VariantID is set the picked product is served in that variant, e.g. "medium".
+type BundleSlot struct {
+	SlotID     string   `bson:"slot_id" json:"slot_id"`
+	Name       string   `bson:"name" json:"name"`
+	ProductIDs []string `bson:"product_ids" json:"product_ids"`
+	VariantID  string   `bson:"variant_id,omitempty" json:"variant_id,omitempty"`
+	Quantity   int      `bson:"quantity" json:"quantity"`
 }
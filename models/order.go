@@ -1,14 +1,114 @@
 package models
 
+const (
+	OrderStatusPending       = "pending"
+	OrderStatusAccepted      = "accepted"
+	OrderStatusInPreparation = "in_preparation"
+	OrderStatusReady         = "ready"
+	OrderStatusPickedUp      = "picked_up"
+	OrderStatusCancelled     = "cancelled"
+	OrderStatusRefunded      = "refunded"
+)
+
+// What happened to the ingredients of an order.
+const (
+	StockReserved = "reserved"
+	StockReleased = "released"
+	StockDeducted = "deducted"
+)
+
 type Order struct {
-	ProductId    string      `bson:"order_id" json:"order_id"`
-	CustomerName string      `bson:"customer_name" json:"customer_name"`
-	Items        []OrderItem `bson:"items" json:"items"`
-	Status       string      `bson:"status" json:"status"`
-	CreatedAt    string      `bson:"created_at" json:"created_at"`
+	ProductId      string         `bson:"order_id" json:"order_id"`
+	CustomerName   string         `bson:"customer_name" json:"customer_name"`
+	Items          []OrderItem    `bson:"items" json:"items"`
+	Status         string         `bson:"status" json:"status"`
+	StatusHistory  []StatusChange `bson:"status_history" json:"status_history"`
+	Refunds        []Refund       `bson:"refunds,omitempty" json:"refunds,omitempty"`
+	Subtotal       float64        `bson:"subtotal" json:"subtotal"`
+	Total          float64        `bson:"total" json:"total"`
+	RefundedAmount float64        `bson:"refunded_amount" json:"refunded_amount"`
+	Stock          string         `bson:"stock,omitempty" json:"stock,omitempty"`
+	ReservedUntil  string         `bson:"reserved_until,omitempty" json:"reserved_until,omitempty"`
+	CreatedAt      string         `bson:"created_at" json:"created_at"`
+	// CustomerID is the client user who placed the order, empty for guest and counter orders.
+	CustomerID string `bson:"customer_id,omitempty" json:"customer_id,omitempty"`
+	// AccessTokenHash is the hash of the token handed out when the order was placed, which lets
+	// whoever holds it see and cancel the order without an account.
+	AccessTokenHash string `bson:"access_token_hash,omitempty" json:"-"`
+	// PickupNumber is the short number, e.g. "#047", the order is called out by. It starts over every day.
+	PickupNumber string `bson:"pickup_number,omitempty" json:"pickup_number,omitempty"`
+}
+
+// PickupBoard lists the pickup numbers of today's orders for the screen customers wait in front of.
+type PickupBoard struct {
+	Preparing []string `json:"preparing"`
+	Ready     []string `json:"ready"`
 }
 
 type OrderItem struct {
 	ProductID string `bson:"product_id" json:"product_id"`
+	// VariantID picks the variant of menu items that come in several, e.g. "large".
+	VariantID string `bson:"variant_id,omitempty" json:"variant_id,omitempty"`
 	Quantity  int    `bson:"quantity" json:"quantity"`
+	// Modifiers are the customizations picked for the item, their names and price deltas are copied from the menu.
+	Modifiers []OrderItemModifier `bson:"modifiers,omitempty" json:"modifiers,omitempty"`
+	// Components are the menu items picked for the slots of a bundle.
+	Components []OrderItemComponent `bson:"components,omitempty" json:"components,omitempty"`
+	// Name and UnitPrice are copied from the menu when the order is placed,
+	// so later menu changes don't rewrite what the customer paid.
+	Name             string  `bson:"name" json:"name"`
+	UnitPrice        float64 `bson:"unit_price" json:"unit_price"`
+	RefundedQuantity int     `bson:"refunded_quantity,omitempty" json:"refunded_quantity,omitempty"`
+	// Station is copied from the menu too, it is the kitchen station the item is prepared at.
+	Station string `bson:"station,omitempty" json:"station,omitempty"`
+	// Ingredients is the per-unit recipe that was reserved or deducted from the inventory for this line,
+	// kept so that cancellations and refunds put back exactly what was taken.
+	Ingredients []MenuItemIngredient `bson:"ingredients,omitempty" json:"ingredients,omitempty"`
+}
+
+// OrderItemModifier is a modifier picked for an order item.
+type OrderItemModifier struct {
+	GroupID    string  `bson:"group_id" json:"group_id"`
+	ModifierID string  `bson:"modifier_id" json:"modifier_id"`
+	Name       string  `bson:"name" json:"name"`
+	PriceDelta float64 `bson:"price_delta" json:"price_delta"`
+}
+
+// OrderItemComponent is the menu item picked for a slot of a bundle. AllocatedPrice is the share of
+// the bundle's unit price attributed to it, in proportion to what the component costs on its own.
+type OrderItemComponent struct {
+	SlotID         string  `bson:"slot_id" json:"slot_id"`
+	ProductID      string  `bson:"product_id" json:"product_id"`
+	VariantID      string  `bson:"variant_id,omitempty" json:"variant_id,omitempty"`
+	Quantity       int     `bson:"quantity" json:"quantity"`
+	Name           string  `bson:"name" json:"name"`
+	AllocatedPrice float64 `bson:"allocated_price" json:"allocated_price"`
+	Station        string  `bson:"station,omitempty" json:"station,omitempty"`
+}
+
+// Refund records money and stock given back for some or all of the order's items.
+type Refund struct {
+	Items      []OrderItem `bson:"items" json:"items"`
+	Amount     float64     `bson:"amount" json:"amount"`
+	Reason     string      `bson:"reason,omitempty" json:"reason,omitempty"`
+	RefundedBy string      `bson:"refunded_by,omitempty" json:"refunded_by,omitempty"`
+	RefundedAt string      `bson:"refunded_at" json:"refunded_at"`
+}
+
+// StatusChange is one entry of an order's status history.
+type StatusChange struct {
+	From      string `bson:"from,omitempty" json:"from,omitempty"`
+	Status    string `bson:"status" json:"status"`
+	ChangedBy string `bson:"changed_by,omitempty" json:"changed_by,omitempty"`
+	ChangedAt string `bson:"changed_at" json:"changed_at"`
+}
+
+type OrderTransitionPayload struct {
+	Status string `json:"status"`
+}
+
+// RefundPayload selects the items to refund, an empty list refunds everything that is left.
+type RefundPayload struct {
+	Items  []OrderItem `json:"items"`
+	Reason string      `json:"reason"`
 }
@@ -0,0 +1,30 @@
+package models
+
+// States of a price change.
+const (
+	PriceChangeScheduled = "scheduled"
+	PriceChangeApplied   = "applied"
+	PriceChangeCancelled = "cancelled"
+)
+
+// PriceChange is one change of the price of a menu item, or of one of its variants. Changes made by
+// editing the item are applied right away, scheduled ones once EffectiveAt has passed.
+type PriceChange struct {
+	ChangeID    string  `bson:"change_id" json:"change_id"`
+	ProductID   string  `bson:"product_id" json:"product_id"`
+	VariantID   string  `bson:"variant_id,omitempty" json:"variant_id,omitempty"`
+	OldPrice    float64 `bson:"old_price" json:"old_price"`
+	NewPrice    float64 `bson:"new_price" json:"new_price"`
+	Status      string  `bson:"status" json:"status"`
+	ChangedBy   string  `bson:"changed_by,omitempty" json:"changed_by,omitempty"`
+	CreatedAt   string  `bson:"created_at" json:"created_at"`
+	EffectiveAt string  `bson:"effective_at" json:"effective_at"`
+	AppliedAt   string  `bson:"applied_at,omitempty" json:"applied_at,omitempty"`
+}
+
+// PriceChangePayload schedules a new price for a menu item, or one of its variants, from EffectiveAt on.
+type PriceChangePayload struct {
+	VariantID   string  `json:"variant_id"`
+	Price       float64 `json:"price"`
+	EffectiveAt string  `json:"effective_at"`
+}
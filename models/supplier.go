@@ -0,0 +1,52 @@
+package models
+
+type Supplier struct {
+	SupplierID string            `bson:"supplier_id" json:"supplier_id"`
+	Name       string            `bson:"name" json:"name"`
+	Email      string            `bson:"email" json:"email"`
+	Phone      string            `bson:"phone" json:"phone"`
+	Products   []SupplierProduct `bson:"products" json:"products"`
+}
+
+// SupplierProduct is an ingredient a supplier sells. It is sold in packs of PackSize, measured
+// in the unit of the inventory item, and UnitCost is the price of one pack.
+type SupplierProduct struct {
+	IngredientID string  `bson:"ingredient_id" json:"ingredient_id"`
+	PackSize     float64 `bson:"pack_size" json:"pack_size"`
+	UnitCost     float64 `bson:"unit_cost" json:"unit_cost"`
+	LeadTimeDays int     `bson:"lead_time_days" json:"lead_time_days"`
+}
+
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+)
+
+type PurchaseOrder struct {
+	PurchaseOrderID string              `bson:"purchase_order_id" json:"purchase_order_id"`
+	SupplierID      string              `bson:"supplier_id" json:"supplier_id"`
+	Status          string              `bson:"status" json:"status"`
+	Lines           []PurchaseOrderLine `bson:"lines" json:"lines"`
+	Total           float64             `bson:"total" json:"total"`
+	CreatedBy       string              `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt       string              `bson:"created_at" json:"created_at"`
+	SentAt          string              `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	ReceivedAt      string              `bson:"received_at,omitempty" json:"received_at,omitempty"`
+}
+
+// PurchaseOrderLine orders Packs packs of an ingredient. PackSize and UnitCost are copied from
+// the supplier's catalog when the line is added.
+type PurchaseOrderLine struct {
+	IngredientID  string  `bson:"ingredient_id" json:"ingredient_id"`
+	Packs         int     `bson:"packs" json:"packs"`
+	PackSize      float64 `bson:"pack_size" json:"pack_size"`
+	UnitCost      float64 `bson:"unit_cost" json:"unit_cost"`
+	ReceivedPacks int     `bson:"received_packs" json:"received_packs"`
+}
+
+// ReceivePayload lists the packs that arrived, an empty list receives everything that is outstanding.
+type ReceivePayload struct {
+	Lines []PurchaseOrderLine `json:"lines"`
+}